@@ -2,15 +2,47 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/robertgouveia/do-my-job/menu"
 	"github.com/robertgouveia/do-my-job/tea"
 )
 
 func main() {
+	sandboxMode := false
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "status":
+			os.Exit(menu.StatusCommand(os.Stdout))
+		case "validate":
+			os.Exit(menu.ValidateCommand(os.Stdout))
+		case "restore":
+			os.Exit(menu.RestoreCommand(os.Stdout, os.Args[2:]))
+		case "sandbox":
+			if len(os.Args) > 2 && os.Args[2] == "reset" {
+				os.Exit(menu.ResetSandboxCommand(os.Stdout))
+			}
+			sandboxMode = true
+		default:
+			log.Fatalf("Unknown command: %s (available: status, validate, restore [snapshot] [--merge] [--confirm <server>], sandbox [reset])", os.Args[1])
+		}
+	}
+
+	tea.SetTheme(menu.LoadTheme())
+	tea.SetKeyMap(menu.LoadKeyMap())
+
 	mainMenu := tea.Create("Server Configuration Tool")
 	menu.ScriptMenu(mainMenu)
+	menu.EnvironmentMenu(mainMenu)
 	menu.ServerMenu(mainMenu)
+	menu.StatusBar(mainMenu)
+
+	if sandboxMode {
+		if _, err := menu.SandboxMenu(mainMenu); err != nil {
+			log.Fatalf("Error starting sandbox: %v", err)
+		}
+	}
 
 	_, err := mainMenu.Run()
 	if err != nil {
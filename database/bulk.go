@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+// bulkProgressRows is how often BulkLoad reports progress.
+const bulkProgressRows = 100
+
+// BulkLoader is implemented by connections that can load many rows into a
+// table faster than one Exec per row.
+type BulkLoader interface {
+	// BulkLoad inserts rows, with values in the order of columns, into
+	// table in one transaction. progress is called with the number of
+	// rows sent so far.
+	BulkLoad(ctx context.Context, table string, columns []string, rows [][]interface{}, progress func(sent int)) (int64, error)
+}
+
+// Import is a CSV file matched up against the table it will be loaded
+// into. Columns holds the target of each CSV column, and Rows the values
+// converted to suit them.
+type Import struct {
+	Table    string
+	Header   []string
+	Columns  []Column
+	Rows     [][]interface{}
+	Problems []string
+}
+
+// PrepareImport reads a CSV file with a header row and checks every value
+// against the columns of table on serverName. Values that will not fit
+// are listed in Problems rather than failing the whole import, so they
+// can all be fixed in one go.
+func PrepareImport(ctx context.Context, connector Connector, serverName, table string, r io.Reader) (Import, error) {
+	header, records, err := readCSV(r)
+	if err != nil {
+		return Import{}, err
+	}
+
+	columns, err := Columns(ctx, connector, serverName, []string{table})
+	if err != nil {
+		return Import{}, err
+	}
+	matched := tableColumns(columns, table)
+	if len(matched) == 0 {
+		return Import{}, fmt.Errorf("table %s does not exist", table)
+	}
+
+	imp := Import{Table: matched[0].Schema + "." + matched[0].Table, Header: header, Columns: make([]Column, len(header))}
+	if matched[0].Schema == "" {
+		imp.Table = matched[0].Table
+	}
+
+	seen := make(map[string]bool)
+	for i, name := range header {
+		column, ok := findColumn(matched, name)
+		switch {
+		case !ok:
+			imp.Problems = append(imp.Problems, fmt.Sprintf("CSV column %q is not a column of %s", name, imp.Table))
+		case seen[strings.ToLower(column.Name)]:
+			imp.Problems = append(imp.Problems, fmt.Sprintf("CSV column %q appears more than once", name))
+		}
+		seen[strings.ToLower(column.Name)] = true
+		imp.Columns[i] = column
+	}
+	if len(imp.Problems) > 0 {
+		return imp, nil
+	}
+
+	imp.Rows = make([][]interface{}, len(records))
+	for i, record := range records {
+		row := make([]interface{}, len(record))
+		for j, text := range record {
+			value, err := imp.Columns[j].Convert(text)
+			if err != nil {
+				// line 1 is the header
+				imp.Problems = append(imp.Problems, fmt.Sprintf("line %d: %v", i+2, err))
+			}
+			row[j] = value
+		}
+		imp.Rows[i] = row
+	}
+
+	return imp, nil
+}
+
+func readCSV(r io.Reader) ([]string, [][]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("the CSV file is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	// Excel saves UTF-8 CSV files with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("the CSV file has no rows below its header")
+	}
+
+	return header, records, nil
+}
+
+// BulkImport loads a prepared import into its table on serverName. It
+// refuses imports with problems, so nothing is loaded until every row
+// fits.
+func BulkImport(ctx context.Context, connector Connector, serverName string, imp Import, progress func(sent, total int)) (int64, error) {
+	if len(imp.Problems) > 0 {
+		return 0, fmt.Errorf("the import has %d problems to fix first", len(imp.Problems))
+	}
+
+	conn, err := connector.Connect(ctx, serverName)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	loader, ok := conn.(BulkLoader)
+	if !ok {
+		return 0, fmt.Errorf("bulk import is not available for %s", conn.Dialect().Name)
+	}
+
+	columns := make([]string, len(imp.Columns))
+	for i, c := range imp.Columns {
+		columns[i] = c.Name
+	}
+
+	return loader.BulkLoad(ctx, imp.Table, columns, imp.Rows, func(sent int) {
+		progress(sent, len(imp.Rows))
+	})
+}
+
+// BulkLoad uses the driver's bulk copy on SQL Server. Other dialects fall
+// back to an INSERT per row, which is slower but lets imports be
+// practised in the sandbox.
+func (c *sqlConn) BulkLoad(ctx context.Context, table string, columns []string, rows [][]interface{}, progress func(sent int)) (int64, error) {
+	tx, err := c.db.BeginTx(ctx, &c.dialect.TxOptions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	report := func(sent int) {
+		if sent%bulkProgressRows == 0 || sent == len(rows) {
+			progress(sent)
+		}
+	}
+
+	var loaded int64
+	if c.dialect.Name == MSSQL.Name {
+		stmt, err := tx.PrepareContext(ctx, mssql.CopyIn(c.dialect.QuoteTable(table), mssql.BulkOptions{CheckConstraints: true}, columns...))
+		if err != nil {
+			return 0, fmt.Errorf("failed to start bulk copy: %w", err)
+		}
+		defer stmt.Close()
+
+		for i, row := range rows {
+			if _, err := stmt.ExecContext(ctx, row...); err != nil {
+				return 0, fmt.Errorf("failed to send row %d: %w", i+1, err)
+			}
+			report(i + 1)
+		}
+
+		// an Exec with no values flushes the rows to the server
+		res, err := stmt.ExecContext(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to finish bulk copy: %w", err)
+		}
+		if loaded, err = res.RowsAffected(); err != nil {
+			return 0, fmt.Errorf("failed to fetch rows affected: %w", err)
+		}
+	} else {
+		quoted := make([]string, len(columns))
+		names := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = c.dialect.Quote(column)
+			names[i] = fmt.Sprintf("@c%d", i+1)
+		}
+		statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			c.dialect.QuoteTable(table), strings.Join(quoted, ", "), strings.Join(names, ", "))
+
+		params := make(map[string]interface{}, len(columns))
+		for i, row := range rows {
+			for j, value := range row {
+				params[fmt.Sprintf("c%d", j+1)] = value
+			}
+			bound, args, err := c.dialect.Bind(statement, params)
+			if err != nil {
+				return 0, err
+			}
+			if _, err := tx.ExecContext(ctx, bound, args...); err != nil {
+				return 0, fmt.Errorf("failed to insert row %d: %w", i+1, err)
+			}
+			loaded++
+			report(i + 1)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return loaded, nil
+}
@@ -0,0 +1,75 @@
+package database_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robertgouveia/do-my-job/database"
+)
+
+// TestBulkImportIntoSandbox covers the INSERT fallback used by every
+// dialect but SQL Server, whose bulk copy needs a real server.
+func TestBulkImportIntoSandbox(t *testing.T) {
+	sandbox, err := database.OpenSandbox(filepath.Join(t.TempDir(), "sandbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	csv := "\ufeffSales Order No_,customer no_,Shipping Agent Code,Shipping Agent Service,Shipment Date\n" +
+		"SO-90001,C0001,DHL,NEXT DAY,2024-04-01\n" +
+		"SO-90002,C0002,UPS,\"ECONOMY, 3 DAY\",2024-04-02\n"
+
+	imp, err := database.PrepareImport(ctx, sandbox, "any", "dbo.Goods Outward Header", strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imp.Problems) != 0 || imp.Table != "dbo.Goods Outward Header" || len(imp.Rows) != 2 {
+		t.Fatalf("unexpected import: %+v", imp)
+	}
+
+	var progress []int
+	loaded, err := database.BulkImport(ctx, sandbox, "any", imp, func(sent, total int) {
+		progress = append(progress, sent)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 2 || len(progress) != 1 || progress[0] != 2 {
+		t.Errorf("loaded %d rows with progress %v", loaded, progress)
+	}
+
+	conn, err := sandbox.Connect(ctx, "any")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	rows, err := conn.Query(ctx, `SELECT [Shipping Agent Service] FROM [Goods Outward Header] WHERE [Sales Order No_] = @OrderNo`, map[string]interface{}{"OrderNo": "SO-90002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows.Values) != 1 || rows.Values[0][0] != "ECONOMY, 3 DAY" {
+		t.Errorf("got %v", rows.Values)
+	}
+}
+
+func TestPrepareImportReportsUnknownColumns(t *testing.T) {
+	sandbox, err := database.OpenSandbox(filepath.Join(t.TempDir(), "sandbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imp, err := database.PrepareImport(context.Background(), sandbox, "any", "Goods Outward Header", strings.NewReader("Sales Order No_,Colour\nSO-1,red\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imp.Problems) != 1 || !strings.Contains(imp.Problems[0], `"Colour"`) {
+		t.Errorf("problems = %q", imp.Problems)
+	}
+
+	if _, err := database.BulkImport(context.Background(), sandbox, "any", imp, func(int, int) {}); err == nil {
+		t.Error("an import with problems should be refused")
+	}
+}
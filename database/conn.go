@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+)
+
+// Rows is a query result read in full. The queries this tool runs return a
+// handful of rows, so reading them up front keeps callers and fakes simple.
+type Rows struct {
+	Columns []string
+	Values  [][]interface{}
+}
+
+type Executor interface {
+	// Exec runs statement with named params and returns the rows affected
+	// along with a printable form of the params for the output screen.
+	Exec(ctx context.Context, statement string, params map[string]interface{}) (int64, string, error)
+}
+
+type Querier interface {
+	Query(ctx context.Context, query string, params map[string]interface{}) (Rows, error)
+}
+
+type Conn interface {
+	Executor
+	Querier
+	Dialect() Dialect
+	Close() error
+}
+
+type Connector interface {
+	Connect(ctx context.Context, serverName string) (Conn, error)
+}
+
+var (
+	defaultConnector   Connector = SQLConnector{}
+	defaultConnectorMu sync.RWMutex
+)
+
+// SetDefault replaces the connector used by the menus, e.g. with a fake
+// from dbtest.
+func SetDefault(c Connector) {
+	defaultConnectorMu.Lock()
+	defer defaultConnectorMu.Unlock()
+	defaultConnector = c
+}
+
+func Default() Connector {
+	defaultConnectorMu.RLock()
+	defer defaultConnectorMu.RUnlock()
+	return defaultConnector
+}
+
+// SQLConnector opens real connections using the saved server configs.
+type SQLConnector struct{}
+
+func (SQLConnector) Connect(ctx context.Context, serverName string) (Conn, error) {
+	db, dialect, err := open(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{db: db, dialect: dialect}, nil
+}
+
+type sqlConn struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// Exec runs the statement in its own transaction so a script that fails
+// part way through leaves nothing behind, where the dialect allows it.
+func (c *sqlConn) Exec(ctx context.Context, statement string, params map[string]interface{}) (int64, string, error) {
+	debugInfo := formatParams(params)
+
+	bound, args, err := c.dialect.Bind(statement, params)
+	if err != nil {
+		return 0, debugInfo, err
+	}
+
+	// one connection for the whole run, so a lock timeout that outlives the
+	// transaction is put back on the connection it was set on
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return 0, debugInfo, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	timeout, withTimeout := LockTimeout(ctx)
+	withTimeout = withTimeout && c.dialect.LockTimeout != nil
+	if withTimeout && c.dialect.ResetLockTimeout != "" {
+		defer resetLockTimeout(context.WithoutCancel(ctx), conn, c.dialect.ResetLockTimeout)
+	}
+
+	tx, err := conn.BeginTx(ctx, &c.dialect.TxOptions)
+	if err != nil {
+		return 0, debugInfo, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if withTimeout {
+		if _, err := tx.ExecContext(ctx, c.dialect.LockTimeout(timeout)); err != nil {
+			return 0, debugInfo, fmt.Errorf("failed to set lock timeout: %w", err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, bound, args...)
+	if err != nil {
+		return 0, debugInfo, fmt.Errorf("failed to execute statement: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, debugInfo, fmt.Errorf("failed to fetch rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, debugInfo, fmt.Errorf("failed to commit: %w", err)
+	}
+	return rows, debugInfo, nil
+}
+
+// resetLockTimeout runs reset on conn, or has the pool throw the connection
+// away when that fails so no later query inherits the script's timeout.
+func resetLockTimeout(ctx context.Context, conn *sql.Conn, reset string) {
+	if _, err := conn.ExecContext(ctx, reset); err != nil {
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+}
+
+func (c *sqlConn) Query(ctx context.Context, query string, params map[string]interface{}) (Rows, error) {
+	bound, args, err := c.dialect.Bind(query, params)
+	if err != nil {
+		return Rows{}, err
+	}
+
+	rows, err := c.db.QueryContext(ctx, bound, args...)
+	if err != nil {
+		return Rows{}, fmt.Errorf("failed to run query: %w", err)
+	}
+	defer rows.Close()
+
+	return readRows(rows)
+}
+
+func readRows(rows *sql.Rows) (Rows, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return Rows{}, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	result := Rows{Columns: columns}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return result, fmt.Errorf("failed to read row: %w", err)
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Values = append(result.Values, values)
+	}
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return result, nil
+}
+
+func (c *sqlConn) Dialect() Dialect {
+	return c.dialect
+}
+
+func (c *sqlConn) Close() error {
+	return c.db.Close()
+}
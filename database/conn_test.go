@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robertgouveia/do-my-job/storage"
+)
+
+// TestSQLiteRoundTrip runs the real SQLConnector against a SQLite file, so
+// binding, transactions and row reading are covered without a server.
+func TestSQLiteRoundTrip(t *testing.T) {
+	storage.SetDefault(storage.NewMemoryStorage())
+	err := storage.SaveServerConfig("local", storage.ServerConfig{
+		Host:    filepath.Join(t.TempDir(), "test.db"),
+		Dialect: storage.DialectSQLite,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	conn, err := SQLConnector{}.Connect(ctx, "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, _, err := conn.Exec(ctx, `CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT, note TEXT)`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, status := range []string{"open", "open", "shipped"} {
+		_, _, err := conn.Exec(ctx, `INSERT INTO orders (id, status, note) VALUES (@ID, @Status, 'by @nobody')`,
+			map[string]interface{}{"ID": id + 1, "Status": status})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, debugInfo, err := conn.Exec(WithLockTimeout(ctx, time.Second), `UPDATE orders SET status = @To WHERE status = @From`,
+		map[string]interface{}{"From": "open", "To": "closed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 || debugInfo != "[From : open] [To : closed] " {
+		t.Errorf("rows = %d, debug = %q", rows, debugInfo)
+	}
+
+	result, err := conn.Query(ctx, `SELECT id, status, note FROM orders WHERE status = @Status ORDER BY id`,
+		map[string]interface{}{"Status": "closed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Values) != 2 || result.Columns[1] != "status" || result.Values[1][2] != "by @nobody" {
+		t.Errorf("unexpected rows: %+v", result)
+	}
+
+	if _, _, err := conn.Exec(ctx, `INSERT INTO orders (id) VALUES (@ID)`, map[string]interface{}{"ID": 1}); err == nil {
+		t.Error("expected a duplicate key error")
+	}
+
+	health := Ping(ctx, "local")
+	if !health.Up || health.Version == "" || health.CheckedAt.IsZero() {
+		t.Errorf("unexpected health: %+v", health)
+	}
+}
+
+func TestConnectRejectsIncompleteConfig(t *testing.T) {
+	storage.SetDefault(storage.NewMemoryStorage())
+	storage.SaveServerConfig("half", storage.ServerConfig{Host: "db.local"})
+
+	_, err := SQLConnector{}.Connect(context.Background(), "half")
+	if err == nil || errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+}
+
+func TestLockTimeoutDoesNotOutliveTheRun(t *testing.T) {
+	storage.SetDefault(storage.NewMemoryStorage())
+	err := storage.SaveServerConfig("local", storage.ServerConfig{
+		Host:    filepath.Join(t.TempDir(), "test.db"),
+		Dialect: storage.DialectSQLite,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	conn, err := SQLConnector{}.Connect(ctx, "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// a single pooled connection, so later queries get the one the script used
+	conn.(*sqlConn).db.SetMaxOpenConns(1)
+
+	if _, _, err := conn.Exec(WithLockTimeout(ctx, time.Second), `CREATE TABLE orders (id INTEGER PRIMARY KEY)`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := conn.Query(ctx, `PRAGMA busy_timeout`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows.Values[0][0]; got != int64(sqliteBusyTimeout) {
+		t.Errorf("busy_timeout after the run = %v, want %d", got, sqliteBusyTimeout)
+	}
+}
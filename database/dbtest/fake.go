@@ -0,0 +1,293 @@
+// Package dbtest provides an in-memory database.Connector so flows that
+// run SQL can be tested without a server.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertgouveia/do-my-job/database"
+)
+
+// Call is one statement or query the fake received.
+type Call struct {
+	Server    string
+	Statement string
+	Params    map[string]interface{}
+	Query     bool
+	// Plan is set for requests for a query plan, which are also queries.
+	Plan bool
+	// LockTimeout is the timeout the statement was run with, if any.
+	LockTimeout time.Duration
+	// Columns and Rows are set for bulk loads and snapshot restores, whose
+	// Statement is INSERT BULK or RESTORE and the table name. Restores
+	// have a merge param.
+	Columns []string
+	Rows    [][]interface{}
+}
+
+type response struct {
+	match        string
+	query        bool
+	plan         bool
+	rowsAffected int64
+	rows         database.Rows
+	showplan     string
+	err          error
+}
+
+// Fake records every statement and answers from scripted responses. A
+// response matches when its text appears in the statement; when several
+// match, they are used in the order they were added and the last one keeps
+// answering.
+type Fake struct {
+	// RowsAffected is returned by Exec when no response matches.
+	RowsAffected int64
+	// Dialect is reported by every connection; it defaults to SQL Server.
+	Dialect database.Dialect
+
+	mu          sync.Mutex
+	calls       []Call
+	responses   []response
+	connectErrs map[string]error
+}
+
+func New() *Fake {
+	return &Fake{RowsAffected: 1, Dialect: database.MSSQL, connectErrs: make(map[string]error)}
+}
+
+func (f *Fake) OnExec(match string, rowsAffected int64, err error) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.responses = append(f.responses, response{match: match, rowsAffected: rowsAffected, err: err})
+	return f
+}
+
+func (f *Fake) OnQuery(match string, rows database.Rows, err error) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.responses = append(f.responses, response{match: match, query: true, rows: rows, err: err})
+	return f
+}
+
+// OnPlan answers plan requests with showplan XML.
+func (f *Fake) OnPlan(match, showplan string, err error) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.responses = append(f.responses, response{match: match, query: true, plan: true, showplan: showplan, err: err})
+	return f
+}
+
+// FailConnect makes Connect to server return err until Reset.
+func (f *Fake) FailConnect(server string, err error) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.connectErrs[server] = err
+	return f
+}
+
+// Calls returns everything run so far, oldest first.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+// Execs returns only the statements run with Exec.
+func (f *Fake) Execs() []Call {
+	var execs []Call
+	for _, call := range f.Calls() {
+		if !call.Query {
+			execs = append(execs, call)
+		}
+	}
+	return execs
+}
+
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = nil
+	f.responses = nil
+	f.connectErrs = make(map[string]error)
+}
+
+func (f *Fake) Connect(ctx context.Context, serverName string) (database.Conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.connectErrs[serverName]; err != nil {
+		return nil, fmt.Errorf("failed to open database: %w -- server: %s", err, serverName)
+	}
+	return &conn{fake: f, server: serverName}, nil
+}
+
+// respond records the call and picks the response for it.
+func (f *Fake) respond(call Call) (response, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, call)
+
+	for i, r := range f.responses {
+		if !r.answers(call) {
+			continue
+		}
+		for _, later := range f.responses[i+1:] {
+			if later.answers(call) {
+				f.responses = append(f.responses[:i], f.responses[i+1:]...)
+				break
+			}
+		}
+		return r, true
+	}
+	return response{}, false
+}
+
+func (r response) answers(call Call) bool {
+	return r.query == call.Query && r.plan == call.Plan && strings.Contains(call.Statement, r.match)
+}
+
+var errClosed = errors.New("connection is closed")
+
+type conn struct {
+	fake   *Fake
+	server string
+	closed bool
+}
+
+func (c *conn) Exec(ctx context.Context, statement string, params map[string]interface{}) (int64, string, error) {
+	debugInfo := formatParams(params)
+	if c.closed {
+		return 0, debugInfo, errClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, debugInfo, err
+	}
+
+	timeout, _ := database.LockTimeout(ctx)
+	r, ok := c.fake.respond(Call{Server: c.server, Statement: statement, Params: copyParams(params), LockTimeout: timeout})
+	if !ok {
+		return c.fake.RowsAffected, debugInfo, nil
+	}
+	return r.rowsAffected, debugInfo, r.err
+}
+
+func (c *conn) Query(ctx context.Context, query string, params map[string]interface{}) (database.Rows, error) {
+	if c.closed {
+		return database.Rows{}, errClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return database.Rows{}, err
+	}
+
+	r, _ := c.fake.respond(Call{Server: c.server, Statement: query, Params: copyParams(params), Query: true})
+	return r.rows, r.err
+}
+
+func (c *conn) Plan(ctx context.Context, statement string, params map[string]interface{}) (string, error) {
+	if c.closed {
+		return "", errClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	r, ok := c.fake.respond(Call{Server: c.server, Statement: statement, Params: copyParams(params), Query: true, Plan: true})
+	if !ok {
+		return "", fmt.Errorf("no plan scripted for %q", statement)
+	}
+	return r.showplan, r.err
+}
+
+// BulkLoad is answered by OnExec responses matching "INSERT BULK <table>"
+// and loads every row when none match.
+func (c *conn) BulkLoad(ctx context.Context, table string, columns []string, rows [][]interface{}, progress func(sent int)) (int64, error) {
+	if c.closed {
+		return 0, errClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	copied := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		copied[i] = append([]interface{}(nil), row...)
+	}
+
+	r, ok := c.fake.respond(Call{Server: c.server, Statement: "INSERT BULK " + table, Columns: append([]string(nil), columns...), Rows: copied})
+	if !ok {
+		progress(len(rows))
+		return int64(len(rows)), nil
+	}
+	return r.rowsAffected, r.err
+}
+
+// Restore is answered by OnExec responses matching "RESTORE <table>" and
+// restores every row when none match.
+func (c *conn) Restore(ctx context.Context, snapshot database.Snapshot, merge bool) (int64, error) {
+	if c.closed {
+		return 0, errClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	columns := make([]string, len(snapshot.Columns))
+	for i, column := range snapshot.Columns {
+		columns[i] = column.Name
+	}
+	rows := make([][]interface{}, len(snapshot.Rows))
+	for i, row := range snapshot.Rows {
+		rows[i] = append([]interface{}(nil), row...)
+	}
+
+	call := Call{Server: c.server, Statement: "RESTORE " + snapshot.Table, Params: map[string]interface{}{"merge": merge}, Columns: columns, Rows: rows}
+	r, ok := c.fake.respond(call)
+	if !ok {
+		return int64(len(rows)), nil
+	}
+	return r.rowsAffected, r.err
+}
+
+func (c *conn) Dialect() database.Dialect {
+	return c.fake.Dialect
+}
+
+func (c *conn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func copyParams(params map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		copied[k] = v
+	}
+	return copied
+}
+
+func formatParams(params map[string]interface{}) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(fmt.Sprintf("[%s : %v] ", name, params[name]))
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestResponsesAreUsedInOrder(t *testing.T) {
+	fake := New().
+		OnExec("UPDATE", 0, errors.New("deadlock")).
+		OnExec("UPDATE", 2, nil)
+
+	conn, err := fake.Connect(context.Background(), "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		rows int64
+		err  bool
+	}{{0, true}, {2, false}, {2, false}}
+	for i, w := range want {
+		rows, _, err := conn.Exec(context.Background(), "UPDATE t SET x = @x", map[string]interface{}{"x": i})
+		if rows != w.rows || (err != nil) != w.err {
+			t.Errorf("call %d: got %d, %v", i, rows, err)
+		}
+	}
+
+	rows, _, err := conn.Exec(context.Background(), "DELETE FROM t", nil)
+	if err != nil || rows != fake.RowsAffected {
+		t.Errorf("unmatched statement: got %d, %v", rows, err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 4 || calls[1].Params["x"] != 1 || calls[0].Server != "db" {
+		t.Errorf("calls not recorded: %+v", calls)
+	}
+}
+
+func TestFailConnect(t *testing.T) {
+	fake := New().FailConnect("db", errors.New("refused"))
+
+	if _, err := fake.Connect(context.Background(), "db"); err == nil {
+		t.Fatal("expected connect to fail")
+	}
+	if _, err := fake.Connect(context.Background(), "other"); err != nil {
+		t.Fatalf("other servers should connect: %v", err)
+	}
+}
+
+func TestClosedConnection(t *testing.T) {
+	conn, _ := New().Connect(context.Background(), "db")
+	conn.Close()
+
+	if _, _, err := conn.Exec(context.Background(), "SELECT 1", nil); err == nil {
+		t.Error("expected an error after Close")
+	}
+}
@@ -0,0 +1,340 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/robertgouveia/do-my-job/storage"
+)
+
+const (
+	diagDialTimeout = 5 * time.Second
+	latencySamples  = 5
+)
+
+type DiagnosticStep struct {
+	Name string
+	OK   bool
+	// Unverified is set when a step could not be checked on its own and
+	// was left to a later step, which is still run.
+	Unverified bool
+	Detail     string
+	Hint       string
+	Duration   time.Duration
+}
+
+// errUnverified is returned by steps that could not tell whether they
+// passed, so the steps after them are still run.
+var errUnverified = errors.New("not confirmed")
+
+type diagnostic struct {
+	config  storage.ServerConfig
+	dialect Dialect
+	host    string
+	port    string
+	db      *sql.DB
+	steps   []DiagnosticStep
+}
+
+type diagnosticStep struct {
+	name string
+	run  func(context.Context) (string, string, error)
+}
+
+// plan lists the checks that apply to the dialect. SQLite is a local file,
+// so there is no network to check, and only SQL Server negotiates TLS in a
+// way worth reporting separately.
+func (d *diagnostic) plan() []diagnosticStep {
+	steps := []diagnosticStep{{"Configuration", d.checkConfig}}
+
+	if d.dialect.Name == storage.DialectSQLite {
+		steps = append(steps, diagnosticStep{"Database file", d.checkFile})
+	} else {
+		steps = append(steps,
+			diagnosticStep{"DNS resolution", d.resolve},
+			diagnosticStep{"TCP connection", d.dial},
+		)
+	}
+	if d.dialect.Name == storage.DialectMSSQL {
+		steps = append(steps, diagnosticStep{"TLS handshake", d.handshake})
+	}
+
+	return append(steps,
+		diagnosticStep{"Login", d.login},
+		diagnosticStep{"Database access", d.databaseAccess},
+		diagnosticStep{"Server version", d.version},
+		diagnosticStep{"Round-trip latency", d.latency},
+	)
+}
+
+// Diagnose walks through each layer of a connection to serverName and stops
+// at the first step that fails, so the last step returned is the culprit.
+func Diagnose(ctx context.Context, serverName string) []DiagnosticStep {
+	config, err := storage.LoadServerConfig(serverName)
+	if err != nil {
+		return []DiagnosticStep{{
+			Name:   "Configuration",
+			Detail: err.Error(),
+			Hint:   "The saved configuration could not be read",
+		}}
+	}
+
+	dialect, err := DialectFor(config)
+	if err != nil {
+		return []DiagnosticStep{{
+			Name:   "Configuration",
+			Detail: err.Error(),
+			Hint:   fmt.Sprintf("Set the dialect to one of: %s", strings.Join(storage.Dialects, ", ")),
+		}}
+	}
+
+	d := &diagnostic{
+		config:  config,
+		dialect: dialect,
+		host:    hostOnly(config.Host),
+		port:    dialect.Port(config),
+	}
+	defer func() {
+		if d.db != nil {
+			d.db.Close()
+		}
+	}()
+
+	for _, step := range d.plan() {
+		start := time.Now()
+		detail, hint, err := step.run(ctx)
+
+		unverified := errors.Is(err, errUnverified)
+		result := DiagnosticStep{
+			Name:       step.name,
+			OK:         err == nil,
+			Unverified: unverified,
+			Detail:     detail,
+			Hint:       hint,
+			Duration:   time.Since(start),
+		}
+		if err != nil && !unverified {
+			result.Detail = err.Error()
+		}
+
+		d.steps = append(d.steps, result)
+		if err != nil && !unverified {
+			break
+		}
+	}
+
+	return d.steps
+}
+
+func (d *diagnostic) checkConfig(ctx context.Context) (string, string, error) {
+	if missing := d.config.Missing(); len(missing) > 0 {
+		return "", "Fill in the missing settings under Configure Servers",
+			fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+
+	if d.dialect.Name == storage.DialectSQLite {
+		return fmt.Sprintf("%s file %s", d.dialect.Name, d.config.Host), "", nil
+	}
+	return fmt.Sprintf("%s %s:%s as %s", d.dialect.Name, d.host, d.port, d.config.Username), "", nil
+}
+
+func (d *diagnostic) checkFile(ctx context.Context) (string, string, error) {
+	info, err := os.Stat(d.config.Host)
+	if err != nil {
+		return "", "Check the path - SQLite would create an empty database here", err
+	}
+	if info.IsDir() {
+		return "", "The path is a directory, not a database file", fmt.Errorf("%s is a directory", d.config.Host)
+	}
+
+	return fmt.Sprintf("%d bytes, modified %s", info.Size(), info.ModTime().Format(time.RFC822)), "", nil
+}
+
+func (d *diagnostic) resolve(ctx context.Context) (string, string, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, d.host)
+	if err != nil {
+		return "", "Host name could not be resolved - check the spelling or whether the VPN is connected", err
+	}
+
+	return "resolved to " + strings.Join(addrs, ", "), "", nil
+}
+
+func (d *diagnostic) dial(ctx context.Context) (string, string, error) {
+	dialer := net.Dialer{Timeout: diagDialTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(d.host, d.port))
+	if err != nil {
+		return "", dialHint(err, d.port), err
+	}
+	defer conn.Close()
+
+	return fmt.Sprintf("%s reachable from %s", conn.RemoteAddr(), conn.LocalAddr()), "", nil
+}
+
+// SQL Server wraps TLS inside its own pre-login packets, so the handshake
+// cannot be tested on its own. The driver reports handshake failures with a
+// recognisable prefix, which is enough to tell them apart from login errors.
+func (d *diagnostic) handshake(ctx context.Context) (string, string, error) {
+	db, err := sql.Open(d.dialect.Driver, connectionString(d.config, "")+"dial timeout=5;")
+	if err != nil {
+		return "", "The connection settings could not be parsed", err
+	}
+	d.db = db
+
+	err = db.PingContext(ctx)
+	if err != nil && strings.Contains(err.Error(), "TLS Handshake failed") {
+		return "", tlsHint(err), err
+	}
+	if err != nil {
+		// Login errors are reported by the next step, but without a
+		// connection there is no telling whether the handshake got through.
+		return "not confirmed - see Login", "", errUnverified
+	}
+
+	var encrypt, transport, auth sql.NullString
+	row := db.QueryRowContext(ctx, `SELECT encrypt_option, net_transport, auth_scheme FROM sys.dm_exec_connections WHERE session_id = @@SPID`)
+	if row.Scan(&encrypt, &transport, &auth) != nil {
+		return "negotiated (details need VIEW SERVER STATE)", "", nil
+	}
+	return fmt.Sprintf("encrypted: %s, transport: %s, auth: %s", encrypt.String, transport.String, auth.String), "", nil
+}
+
+func (d *diagnostic) login(ctx context.Context) (string, string, error) {
+	if d.db == nil {
+		db, err := sql.Open(d.dialect.Driver, d.dialect.DSN(d.config, d.config.Database))
+		if err != nil {
+			return "", "The connection settings could not be parsed", err
+		}
+		d.db = db
+	}
+
+	if err := d.db.PingContext(ctx); err != nil {
+		return "", loginHint(err, d.config.Username), err
+	}
+
+	if d.dialect.UserQuery == "" {
+		return "opened", "", nil
+	}
+
+	var login string
+	if err := d.db.QueryRowContext(ctx, d.dialect.UserQuery).Scan(&login); err != nil {
+		return "", "", err
+	}
+
+	return "logged in as " + login, "", nil
+}
+
+// databaseAccess is only a separate step on SQL Server, where login happens
+// before a database is chosen. Other dialects connect to the database
+// directly, so reaching this step means it is accessible.
+func (d *diagnostic) databaseAccess(ctx context.Context) (string, string, error) {
+	if d.dialect.Name != storage.DialectMSSQL {
+		if d.config.Database == "" {
+			return "no database selected", "", nil
+		}
+		return d.config.Database + " is accessible", "", nil
+	}
+
+	var access sql.NullInt64
+	err := d.db.QueryRowContext(ctx, `SELECT HAS_DBACCESS(@p1)`, d.config.Database).Scan(&access)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !access.Valid {
+		return "", "Check the database name - it does not exist on this server",
+			fmt.Errorf("database %q not found", d.config.Database)
+	}
+	if access.Int64 != 1 {
+		return "", fmt.Sprintf("Ask a DBA to grant %s access to %s", d.config.Username, d.config.Database),
+			fmt.Errorf("no access to database %q", d.config.Database)
+	}
+
+	return d.config.Database + " is accessible", "", nil
+}
+
+func (d *diagnostic) version(ctx context.Context) (string, string, error) {
+	var version string
+	if err := d.db.QueryRowContext(ctx, d.dialect.VersionQuery).Scan(&version); err != nil {
+		return "", "", err
+	}
+
+	return version, "", nil
+}
+
+func (d *diagnostic) latency(ctx context.Context) (string, string, error) {
+	var total, worst time.Duration
+	for i := 0; i < latencySamples; i++ {
+		start := time.Now()
+		if _, err := d.db.ExecContext(ctx, `SELECT 1`); err != nil {
+			return "", "The connection dropped while measuring latency", err
+		}
+		elapsed := time.Since(start)
+
+		total += elapsed
+		if elapsed > worst {
+			worst = elapsed
+		}
+	}
+
+	avg := total / latencySamples
+	hint := ""
+	if avg > 200*time.Millisecond {
+		hint = "Latency is high - scripts may be slow over this link"
+	}
+
+	return fmt.Sprintf("avg %s, max %s over %d queries", avg.Round(time.Microsecond), worst.Round(time.Microsecond), latencySamples), hint, nil
+}
+
+// hostOnly strips a named instance (host\INSTANCE) so the host can be
+// resolved and dialled directly.
+func hostOnly(host string) string {
+	if i := strings.Index(host, `\`); i >= 0 {
+		return host[:i]
+	}
+	return host
+}
+
+func dialHint(err error, port string) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Sprintf("Timed out - VPN not connected or a firewall is blocking port %s", port)
+	}
+	if strings.Contains(err.Error(), "refused") {
+		return fmt.Sprintf("Connection refused - the database is not listening on port %s", port)
+	}
+	return "The server could not be reached - check the VPN and network connection"
+}
+
+func tlsHint(err error) string {
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) || strings.Contains(err.Error(), "certificate") {
+		return "The server certificate is not trusted - install its CA or enable TrustServerCertificate"
+	}
+	return "Encryption could not be negotiated - check the server's TLS settings"
+}
+
+func loginHint(err error, username string) string {
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) {
+		switch sqlErr.Number {
+		case 18456:
+			return fmt.Sprintf("Login failed for user %s - check the username and password", username)
+		case 18452:
+			return "The server only accepts Windows authentication"
+		case 18486:
+			return "The login is locked out - ask a DBA to unlock it"
+		case 18487, 18488:
+			return "The password has expired and must be changed"
+		}
+	}
+	return "The server rejected the connection"
+}
@@ -0,0 +1,55 @@
+package database_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/storage"
+)
+
+func TestDiagnoseDoesNotPassAnUnconfirmedHandshake(t *testing.T) {
+	storage.SetDefault(storage.NewMemoryStorage())
+
+	// accepts connections and drops them before the pre-login exchange
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	err = storage.SaveServerConfig("dropped", storage.ServerConfig{
+		Host: host, Port: port, Username: "sa", Database: "NAV", Dialect: storage.DialectMSSQL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := database.Diagnose(context.Background(), "dropped")
+	byName := make(map[string]database.DiagnosticStep, len(steps))
+	for _, step := range steps {
+		byName[step.Name] = step
+	}
+
+	handshake, ok := byName["TLS handshake"]
+	if !ok {
+		t.Fatalf("no TLS handshake step in %+v", steps)
+	}
+	if handshake.OK || !handshake.Unverified {
+		t.Errorf("handshake should be unverified when the connection fails, got %+v", handshake)
+	}
+	if login, ok := byName["Login"]; !ok || login.OK {
+		t.Errorf("the failure should be reported by Login, got %+v", steps)
+	}
+}
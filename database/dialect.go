@@ -0,0 +1,357 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	_ "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/robertgouveia/do-my-job/lib"
+	"github.com/robertgouveia/do-my-job/storage"
+	_ "modernc.org/sqlite"
+)
+
+// A Dialect describes how to reach and talk to one kind of database.
+// Scripts are always written with @name parameters and [bracketed]
+// identifiers; Bind rewrites them into whatever the database expects.
+type Dialect struct {
+	Name        string
+	Driver      string
+	DefaultPort string
+
+	// NamedParams passes values with sql.Named and leaves @name in the
+	// statement. Otherwise each @name becomes Placeholder(n), counting
+	// from 1, and values are passed positionally.
+	NamedParams bool
+	Placeholder func(n int) string
+	// ReusePlaceholders is set when Placeholder(n) may appear more than
+	// once, so a repeated @name binds one value instead of two.
+	ReusePlaceholders bool
+
+	QuoteOpen  string
+	QuoteClose string
+
+	// TxOptions is used for every script run.
+	TxOptions sql.TxOptions
+	// LockTimeout is run at the start of the transaction to stop waiting
+	// for locks after d.
+	LockTimeout func(d time.Duration) string
+	// ResetLockTimeout puts back the timeout a connection opens with, where
+	// LockTimeout outlives the transaction, before it returns to the pool.
+	ResetLockTimeout string
+
+	VersionQuery string
+	UserQuery    string
+	// ColumnsQuery lists the columns of the tables named in its IN (%s)
+	// clause as schema, table, column, type, max length, nullable, and
+	// optionally whether the server fills the column itself.
+	ColumnsQuery string
+	// KeyQuery lists the primary key columns of the table named by @schema
+	// and @table, in key order.
+	KeyQuery string
+	// PermissionCheck is an expression taking a table then a permission
+	// name, as %s, that is true when the login holds the permission. It is
+	// empty where there is no simple way to ask.
+	PermissionCheck string
+
+	dsn func(s storage.ServerConfig, database string) string
+}
+
+var (
+	MSSQL = Dialect{
+		Name:            storage.DialectMSSQL,
+		Driver:          "sqlserver",
+		DefaultPort:     "1433",
+		NamedParams:     true,
+		QuoteOpen:       "[",
+		QuoteClose:      "]",
+		TxOptions:       sql.TxOptions{Isolation: sql.LevelReadCommitted},
+		LockTimeout:     func(d time.Duration) string { return fmt.Sprintf("SET LOCK_TIMEOUT %d", d.Milliseconds()) },
+		VersionQuery:    `SELECT CAST(SERVERPROPERTY('ProductVersion') AS nvarchar(128)) + ' ' + CAST(SERVERPROPERTY('Edition') AS nvarchar(128))`,
+		UserQuery:       `SELECT SUSER_SNAME()`,
+		ColumnsQuery:    mssqlColumns,
+		KeyQuery:        informationSchemaKey,
+		PermissionCheck: `HAS_PERMS_BY_NAME(%s, 'OBJECT', %s)`,
+		dsn:             connectionString,
+	}
+
+	Postgres = Dialect{
+		Name:              storage.DialectPostgres,
+		Driver:            "pgx",
+		DefaultPort:       "5432",
+		Placeholder:       func(n int) string { return fmt.Sprintf("$%d", n) },
+		ReusePlaceholders: true,
+		QuoteOpen:         `"`,
+		QuoteClose:        `"`,
+		TxOptions:         sql.TxOptions{Isolation: sql.LevelReadCommitted},
+		LockTimeout:       func(d time.Duration) string { return fmt.Sprintf("SET LOCAL lock_timeout = %d", d.Milliseconds()) },
+		VersionQuery:      `SELECT version()`,
+		UserQuery:         `SELECT current_user`,
+		ColumnsQuery:      informationSchemaColumns,
+		KeyQuery:          informationSchemaKey,
+		// to_regclass gives NULL for a missing table rather than an error
+		PermissionCheck: `has_table_privilege(to_regclass(%s), %s)`,
+		dsn:             postgresDSN,
+	}
+
+	MySQL = Dialect{
+		Name:        storage.DialectMySQL,
+		Driver:      "mysql",
+		DefaultPort: "3306",
+		Placeholder: func(int) string { return "?" },
+		QuoteOpen:   "`",
+		QuoteClose:  "`",
+		TxOptions:   sql.TxOptions{Isolation: sql.LevelRepeatableRead},
+		// InnoDB counts in whole seconds
+		LockTimeout: func(d time.Duration) string {
+			return fmt.Sprintf("SET SESSION innodb_lock_wait_timeout = %d", max(int64(math.Ceil(d.Seconds())), 1))
+		},
+		ResetLockTimeout: "SET SESSION innodb_lock_wait_timeout = DEFAULT",
+		VersionQuery:     `SELECT CONCAT('MySQL ', VERSION())`,
+		UserQuery:        `SELECT CURRENT_USER()`,
+		ColumnsQuery: `SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, IS_NULLABLE
+FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME IN (%s)
+ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+		KeyQuery: informationSchemaKey,
+		dsn:      mysqlDSN,
+	}
+
+	// SQLite treats Host as the path to the database file.
+	SQLite = Dialect{
+		Name:              storage.DialectSQLite,
+		Driver:            "sqlite",
+		Placeholder:       func(n int) string { return fmt.Sprintf("?%d", n) },
+		ReusePlaceholders: true,
+		QuoteOpen:         `"`,
+		QuoteClose:        `"`,
+		LockTimeout:       func(d time.Duration) string { return fmt.Sprintf("PRAGMA busy_timeout = %d", d.Milliseconds()) },
+		ResetLockTimeout:  fmt.Sprintf("PRAGMA busy_timeout = %d", sqliteBusyTimeout),
+		VersionQuery:      `SELECT 'SQLite ' || sqlite_version()`,
+		// SQLite has no INFORMATION_SCHEMA; the pragmas give the same facts
+		ColumnsQuery: `SELECT t.schema, t.name, c.name, c.type, NULL, CASE WHEN c."notnull" = 1 THEN 'NO' ELSE 'YES' END
+FROM pragma_table_list t JOIN pragma_table_info(t.name, t.schema) c
+WHERE t.type = 'table' AND t.name IN (%s)
+ORDER BY t.schema, t.name, c.cid`,
+		KeyQuery: `SELECT name FROM pragma_table_info(@table, @schema) WHERE pk > 0 ORDER BY pk`,
+		dsn:      sqliteDSN,
+	}
+)
+
+// sqliteBusyTimeout is how many milliseconds SQLite connections wait on a
+// locked database by default.
+const sqliteBusyTimeout = 5000
+
+const informationSchemaColumns = `SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, IS_NULLABLE
+FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_NAME IN (%s)
+ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION`
+
+const informationSchemaKey = `SELECT k.COLUMN_NAME
+FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS c
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE k
+	ON k.CONSTRAINT_SCHEMA = c.CONSTRAINT_SCHEMA AND k.CONSTRAINT_NAME = c.CONSTRAINT_NAME
+	AND k.TABLE_SCHEMA = c.TABLE_SCHEMA AND k.TABLE_NAME = c.TABLE_NAME
+WHERE c.CONSTRAINT_TYPE = 'PRIMARY KEY' AND c.TABLE_SCHEMA = @schema AND c.TABLE_NAME = @table
+ORDER BY k.ORDINAL_POSITION`
+
+// mssqlColumns names rowversion columns as such rather than by their old
+// timestamp alias, which is not a date, and flags rowversion, identity and
+// computed columns, which cannot be written.
+const mssqlColumns = `SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME,
+	CASE WHEN DATA_TYPE = 'timestamp' THEN 'rowversion' ELSE DATA_TYPE END,
+	CHARACTER_MAXIMUM_LENGTH, IS_NULLABLE,
+	CASE WHEN DATA_TYPE = 'timestamp'
+		OR COLUMNPROPERTY(OBJECT_ID(QUOTENAME(TABLE_SCHEMA) + '.' + QUOTENAME(TABLE_NAME)), COLUMN_NAME, 'IsIdentity') = 1
+		OR COLUMNPROPERTY(OBJECT_ID(QUOTENAME(TABLE_SCHEMA) + '.' + QUOTENAME(TABLE_NAME)), COLUMN_NAME, 'IsComputed') = 1
+	THEN 1 ELSE 0 END
+FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_NAME IN (%s)
+ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION`
+
+var dialects = map[string]Dialect{
+	MSSQL.Name:    MSSQL,
+	Postgres.Name: Postgres,
+	MySQL.Name:    MySQL,
+	SQLite.Name:   SQLite,
+}
+
+func DialectFor(s storage.ServerConfig) (Dialect, error) {
+	d, ok := dialects[s.DialectName()]
+	if !ok {
+		return Dialect{}, fmt.Errorf("unknown dialect: %s", s.DialectName())
+	}
+	return d, nil
+}
+
+// DSN builds the driver connection string for s. An empty database
+// connects without selecting one where the driver allows it.
+func (d Dialect) DSN(s storage.ServerConfig, database string) string {
+	return d.dsn(s, database)
+}
+
+func (d Dialect) Port(s storage.ServerConfig) string {
+	if s.Port != "" {
+		return s.Port
+	}
+	return d.DefaultPort
+}
+
+func (d Dialect) Quote(identifier string) string {
+	return d.QuoteOpen + strings.ReplaceAll(identifier, d.QuoteClose, d.QuoteClose+d.QuoteClose) + d.QuoteClose
+}
+
+// QuoteTable quotes each part of a schema.table name.
+func (d Dialect) QuoteTable(name string) string {
+	parts := strings.Split(name, ".")
+	for i := range parts {
+		parts[i] = d.Quote(parts[i])
+	}
+	return strings.Join(parts, ".")
+}
+
+// Bind rewrites the @name parameters in statement for the dialect and
+// returns the values in the order the driver needs them. [Bracketed]
+// identifiers are quoted the dialect's way. @ inside string literals,
+// quoted identifiers and comments is left alone, as is @@name.
+func (d Dialect) Bind(statement string, params map[string]interface{}) (string, []interface{}, error) {
+	var b strings.Builder
+	var args []interface{}
+	positions := make(map[string]int)
+
+	bind := func(name string) error {
+		value, ok := params[name]
+		if !ok {
+			return fmt.Errorf("missing parameter: %s", name)
+		}
+
+		if d.NamedParams {
+			b.WriteString("@" + name)
+			if _, seen := positions[name]; !seen {
+				positions[name] = len(args)
+				args = append(args, sql.Named(name, value))
+			}
+			return nil
+		}
+
+		if n, seen := positions[name]; seen && d.ReusePlaceholders {
+			b.WriteString(d.Placeholder(n))
+			return nil
+		}
+		args = append(args, value)
+		positions[name] = len(args)
+		b.WriteString(d.Placeholder(len(args)))
+		return nil
+	}
+
+	for i := 0; i < len(statement); {
+		c := statement[i]
+
+		if end := skipQuoted(statement, i); c == '[' && end > i+1 && statement[end-1] == ']' {
+			b.WriteString(d.Quote(strings.ReplaceAll(statement[i+1:end-1], "]]", "]")))
+			i = end
+			continue
+		}
+
+		if end := skipQuoted(statement, i); end > i {
+			b.WriteString(statement[i:end])
+			i = end
+			continue
+		}
+
+		if c == '@' && i+1 < len(statement) && statement[i+1] == '@' {
+			end := i + 2
+			for end < len(statement) && isNameChar(statement[end]) {
+				end++
+			}
+			b.WriteString(statement[i:end])
+			i = end
+			continue
+		}
+
+		if c == '@' && i+1 < len(statement) && isNameChar(statement[i+1]) {
+			end := i + 1
+			for end < len(statement) && isNameChar(statement[end]) {
+				end++
+			}
+			if err := bind(statement[i+1 : end]); err != nil {
+				return "", nil, err
+			}
+			i = end
+			continue
+		}
+
+		b.WriteByte(c)
+		i++
+	}
+
+	return b.String(), args, nil
+}
+
+// quoted pairs each opening of a literal, quoted identifier or comment
+// with what closes it.
+var quoted = [][2]string{{"'", "'"}, {`"`, `"`}, {"`", "`"}, {"[", "]"}, {"--", "\n"}, {"/*", "*/"}}
+
+// skipQuoted returns the end of the literal, quoted identifier or comment
+// starting at i, or i when there is none.
+func skipQuoted(s string, i int) int {
+	for _, pair := range quoted {
+		open, close := pair[0], pair[1]
+		if !strings.HasPrefix(s[i:], open) {
+			continue
+		}
+
+		j := i + len(open)
+		for {
+			k := strings.Index(s[j:], close)
+			if k < 0 {
+				return len(s)
+			}
+			j += k + len(close)
+			// a doubled quote is an escaped quote, not the end
+			if open == close || open == "[" {
+				if strings.HasPrefix(s[j:], close) {
+					j += len(close)
+					continue
+				}
+			}
+			return j
+		}
+	}
+	return i
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func postgresDSN(s storage.ServerConfig, database string) string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(s.Username, s.Password),
+		Host:   net.JoinHostPort(s.Host, lib.StringOrDefault(s.Port, "5432")),
+		Path:   "/" + database,
+	}
+	return u.String()
+}
+
+func mysqlDSN(s storage.ServerConfig, database string) string {
+	config := mysql.NewConfig()
+	config.User = s.Username
+	config.Passwd = s.Password
+	config.Net = "tcp"
+	config.Addr = net.JoinHostPort(s.Host, lib.StringOrDefault(s.Port, "3306"))
+	config.DBName = database
+	config.ParseTime = true
+	return config.FormatDSN()
+}
+
+func sqliteDSN(s storage.ServerConfig, _ string) string {
+	return fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=foreign_keys(1)", s.Host, sqliteBusyTimeout)
+}
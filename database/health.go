@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Health struct {
+	Server    string
+	Up        bool
+	Latency   time.Duration
+	Version   string
+	Err       error
+	CheckedAt time.Time
+}
+
+func Ping(ctx context.Context, serverName string) (health Health) {
+	health.Server = serverName
+	start := time.Now()
+	defer func() {
+		health.CheckedAt = time.Now()
+	}()
+
+	conn, err := Default().Connect(ctx, serverName)
+	if err != nil {
+		health.Err = err
+		return health
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query(ctx, conn.Dialect().VersionQuery, nil)
+	if err != nil {
+		health.Err = err
+		return health
+	}
+
+	version := ""
+	if len(rows.Values) > 0 && len(rows.Values[0]) > 0 {
+		version = fmt.Sprint(rows.Values[0][0])
+	}
+
+	health.Up = true
+	health.Latency = time.Since(start)
+	health.Version = version
+	return health
+}
+
+// PingAll checks every server concurrently, giving each its own timeout so
+// one unreachable box cannot hold up the rest. Results keep the input order.
+func PingAll(ctx context.Context, serverNames []string, timeout time.Duration) []Health {
+	results := make([]Health, len(serverNames))
+
+	var wg sync.WaitGroup
+	for i, name := range serverNames {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+
+			serverCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			results[i] = Ping(serverCtx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrLockCheckUnsupported = errors.New("lock checks are only available on SQL Server")
+
+type lockTimeoutKey struct{}
+
+// WithLockTimeout makes statements run with the returned context stop
+// waiting for locks after d, so a run fails fast instead of hanging behind
+// another session. Zero or less leaves the server default.
+func WithLockTimeout(ctx context.Context, d time.Duration) context.Context {
+	if d <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, lockTimeoutKey{}, d)
+}
+
+func LockTimeout(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(lockTimeoutKey{}).(time.Duration)
+	return d, ok
+}
+
+// tableName matches a table name of up to three parts, each bracketed or
+// plain.
+const tableName = `(?:\[[^\]]+\]|[A-Za-z_][\w$#]*)(?:\s*\.\s*(?:\[[^\]]+\]|[A-Za-z_][\w$#]*))*`
+
+var (
+	writeStatement = regexp.MustCompile(`(?i)\b(UPDATE|INSERT|DELETE|MERGE|TRUNCATE)\b`)
+	tableReference = regexp.MustCompile(`(?i)\b(?:UPDATE|INTO|FROM|JOIN|TABLE)\s+(` + tableName + `)`)
+	namePart       = regexp.MustCompile(`\[[^\]]+\]|[^.\s]+`)
+)
+
+// IsWrite reports whether statement changes data.
+func IsWrite(statement string) bool {
+	return writeStatement.MatchString(stripQuoted(statement, false))
+}
+
+// StatementTables lists the tables statement names, as schema.table
+// without brackets, once each and in order of appearance.
+func StatementTables(statement string) []string {
+	var tables []string
+	seen := make(map[string]bool)
+
+	stripped := stripQuoted(statement, true)
+	keywords := stripQuoted(statement, false)
+
+	for _, loc := range tableReference.FindAllStringSubmatchIndex(stripped, -1) {
+		// Blanking identifiers keeps offsets, so a keyword that survives it
+		// is SQL rather than part of a bracketed name.
+		if keywords[loc[0]] == ' ' {
+			continue
+		}
+		table := normaliseTable(stripped[loc[2]:loc[3]])
+		if !seen[strings.ToLower(table)] {
+			seen[strings.ToLower(table)] = true
+			tables = append(tables, table)
+		}
+	}
+
+	return tables
+}
+
+// normaliseTable turns a table name as written in SQL into schema.table,
+// dropping brackets and any database name.
+func normaliseTable(name string) string {
+	parts := namePart.FindAllString(name, -1)
+	for i := range parts {
+		parts[i] = unbracket(parts[i])
+	}
+	if len(parts) > 2 {
+		parts = parts[len(parts)-2:]
+	}
+	return strings.Join(parts, ".")
+}
+
+// stripQuoted blanks out string literals and comments so keywords inside
+// them are not mistaken for SQL. Bracketed identifiers are blanked too
+// unless keepIdentifiers is set.
+func stripQuoted(statement string, keepIdentifiers bool) string {
+	var b strings.Builder
+	for i := 0; i < len(statement); {
+		end := skipQuoted(statement, i)
+		switch {
+		case end == i:
+			b.WriteByte(statement[i])
+			i++
+			continue
+		case keepIdentifiers && statement[i] == '[':
+			b.WriteString(statement[i:end])
+		default:
+			b.WriteString(strings.Repeat(" ", end-i))
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// LockHolder is another session holding or waiting for locks on the
+// tables being checked.
+type LockHolder struct {
+	SessionID int64
+	Login     string
+	Host      string
+	Program   string
+	Tables    []string
+	Modes     []string
+	// TableModes are the modes held on whole tables, as opposed to rows,
+	// keys or pages.
+	TableModes         []string
+	Waiting            bool
+	TransactionStarted time.Time
+	BlockedBy          int64
+	Blocking           int64
+	WaitType           string
+	Wait               time.Duration
+}
+
+// tableBlockers are the table lock modes that cannot share a table with
+// the intent exclusive lock every write takes.
+var tableBlockers = map[string]bool{"S": true, "U": true, "SIX": true, "X": true, "Sch-M": true}
+
+// Conflicts reports whether a write would have to wait for the session:
+// it is waiting or blocking others, or holds a table lock a write cannot
+// share. Intent locks and row, key and page locks are routine on a busy
+// table and rarely on the rows a script changes, so they do not count.
+func (h LockHolder) Conflicts() bool {
+	if h.Waiting || h.Blocking > 0 {
+		return true
+	}
+	for _, mode := range h.TableModes {
+		if tableBlockers[mode] {
+			return true
+		}
+	}
+	return false
+}
+
+type LockReport struct {
+	Tables  []string
+	Holders []LockHolder
+}
+
+// Conflicts returns the sessions a write to the tables would wait for.
+func (r LockReport) Conflicts() []LockHolder {
+	var conflicts []LockHolder
+	for _, h := range r.Holders {
+		if h.Conflicts() {
+			conflicts = append(conflicts, h)
+		}
+	}
+	return conflicts
+}
+
+// lockQuery finds locks held or requested by other sessions on the objects
+// listed in its IN clause. Row and page locks are traced back to their
+// table through sys.partitions. Needs VIEW SERVER STATE.
+const lockQuery = `SELECT DISTINCT
+	l.request_session_id,
+	OBJECT_SCHEMA_NAME(o.object_id) + '.' + OBJECT_NAME(o.object_id),
+	l.request_mode,
+	l.request_status,
+	s.login_name,
+	s.host_name,
+	s.program_name,
+	at.transaction_begin_time,
+	ISNULL(r.blocking_session_id, 0),
+	ISNULL(r.wait_type, ''),
+	ISNULL(r.wait_time, 0),
+	(SELECT COUNT(*) FROM sys.dm_exec_requests b WHERE b.blocking_session_id = l.request_session_id),
+	l.resource_type
+FROM sys.dm_tran_locks l
+CROSS APPLY (
+	SELECT CASE WHEN l.resource_type = 'OBJECT' THEN l.resource_associated_entity_id
+		ELSE (SELECT TOP 1 p.object_id FROM sys.partitions p WHERE p.hobt_id = l.resource_associated_entity_id)
+	END AS object_id
+) o
+JOIN sys.dm_exec_sessions s ON s.session_id = l.request_session_id
+LEFT JOIN sys.dm_tran_session_transactions st ON st.session_id = l.request_session_id
+LEFT JOIN sys.dm_tran_active_transactions at ON at.transaction_id = st.transaction_id
+LEFT JOIN sys.dm_exec_requests r ON r.session_id = l.request_session_id
+WHERE l.resource_database_id = DB_ID()
+	AND l.resource_type IN ('OBJECT', 'HOBT', 'PAGE', 'KEY', 'RID')
+	AND l.request_session_id <> @@SPID
+	AND l.request_mode <> 'Sch-S'
+	AND o.object_id IN (%s)
+ORDER BY 1`
+
+// CheckLocks looks for other sessions holding or waiting for locks on
+// tables in serverName's database.
+func CheckLocks(ctx context.Context, connector Connector, serverName string, tables []string) (LockReport, error) {
+	report := LockReport{Tables: tables}
+	if len(tables) == 0 {
+		return report, nil
+	}
+
+	conn, err := connector.Connect(ctx, serverName)
+	if err != nil {
+		return report, err
+	}
+	defer conn.Close()
+
+	if conn.Dialect().Name != MSSQL.Name {
+		return report, ErrLockCheckUnsupported
+	}
+
+	params := make(map[string]interface{}, len(tables))
+	ids := make([]string, len(tables))
+	for i, table := range tables {
+		name := fmt.Sprintf("t%d", i+1)
+		ids[i] = fmt.Sprintf("OBJECT_ID(@%s)", name)
+
+		parts := strings.Split(table, ".")
+		for j := range parts {
+			parts[j] = MSSQL.Quote(parts[j])
+		}
+		params[name] = strings.Join(parts, ".")
+	}
+
+	rows, err := conn.Query(ctx, fmt.Sprintf(lockQuery, strings.Join(ids, ", ")), params)
+	if err != nil {
+		return report, fmt.Errorf("failed to check locks: %w", err)
+	}
+
+	holders := make(map[int64]*LockHolder)
+	var order []int64
+	for _, row := range rows.Values {
+		if len(row) < 13 {
+			return report, fmt.Errorf("failed to check locks: expected 13 columns, got %d", len(row))
+		}
+
+		id := toInt64(row[0])
+		h, ok := holders[id]
+		if !ok {
+			h = &LockHolder{
+				SessionID:          id,
+				Login:              toString(row[4]),
+				Host:               toString(row[5]),
+				Program:            toString(row[6]),
+				TransactionStarted: toTime(row[7]),
+				BlockedBy:          toInt64(row[8]),
+				WaitType:           toString(row[9]),
+				Wait:               time.Duration(toInt64(row[10])) * time.Millisecond,
+				Blocking:           toInt64(row[11]),
+			}
+			holders[id] = h
+			order = append(order, id)
+		}
+
+		h.Tables = appendUnique(h.Tables, toString(row[1]))
+		h.Modes = appendUnique(h.Modes, toString(row[2]))
+		if toString(row[12]) == "OBJECT" {
+			h.TableModes = appendUnique(h.TableModes, toString(row[2]))
+		}
+		if toString(row[3]) != "GRANT" {
+			h.Waiting = true
+		}
+	}
+
+	for _, id := range order {
+		report.Holders = append(report.Holders, *holders[id])
+	}
+
+	return report, nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case uint8:
+		return int64(n)
+	}
+	return 0
+}
+
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func toTime(v interface{}) time.Time {
+	t, _ := v.(time.Time)
+	return t
+}
@@ -0,0 +1,84 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/database/dbtest"
+)
+
+func TestStatementTables(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{database.ShippingChange, []string{"dbo.Goods Outward Header"}},
+		{`UPDATE h SET h.[Status] = 1 FROM [NAV].[dbo].[Sales Header] h JOIN dbo.Customer c ON c.No_ = h.[Sell-to Customer No_]`,
+			[]string{"h", "dbo.Sales Header", "dbo.Customer"}},
+		{`DELETE FROM Orders WHERE note = 'copied from Archive'`, []string{"Orders"}},
+		{`INSERT INTO [dbo].[Log] (msg) VALUES ('update from here') -- JOIN Nothing`, []string{"dbo.Log"}},
+	}
+
+	for _, tt := range tests {
+		if got := database.StatementTables(tt.statement); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("StatementTables(%q) = %q, want %q", tt.statement, got, tt.want)
+		}
+	}
+}
+
+func TestIsWrite(t *testing.T) {
+	if !database.IsWrite(database.DisputeChange) {
+		t.Error("UPDATE should be a write")
+	}
+	if database.IsWrite(`SELECT [Update Count] FROM t WHERE note = 'delete me'`) {
+		t.Error("a SELECT naming updates in identifiers and strings is not a write")
+	}
+}
+
+func TestCheckLocks(t *testing.T) {
+	started := time.Date(2026, 3, 2, 9, 15, 0, 0, time.UTC)
+	fake := dbtest.New().OnQuery("dm_tran_locks", database.Rows{Values: [][]interface{}{
+		{int64(87), "dbo.Goods Outward Header", "IX", "GRANT", "NAVSVC", "WH-APP01", "Job Queue", started, int64(0), "", int64(0), int64(1), "OBJECT"},
+		{int64(87), "dbo.Goods Outward Header", "X", "GRANT", "NAVSVC", "WH-APP01", "Job Queue", started, int64(0), "", int64(0), int64(1), "KEY"},
+		{int64(91), "dbo.Goods Outward Header", "IS", "GRANT", "jsmith", "PC-114", "SSMS", nil, int64(0), "", int64(0), int64(0), "OBJECT"},
+		// row locks of another writer on other rows do not get in the way
+		{int64(95), "dbo.Goods Outward Header", "IX", "GRANT", "NAVSVC", "WH-APP02", "Client", started, int64(0), "", int64(0), int64(0), "OBJECT"},
+		{int64(95), "dbo.Goods Outward Header", "X", "GRANT", "NAVSVC", "WH-APP02", "Client", started, int64(0), "", int64(0), int64(0), "KEY"},
+		{int64(96), "dbo.Goods Outward Header", "S", "GRANT", "jsmith", "PC-114", "SSMS", nil, int64(0), "", int64(0), int64(0), "OBJECT"},
+	}}, nil)
+
+	report, err := database.CheckLocks(context.Background(), fake, "db", []string{"dbo.Goods Outward Header"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Holders) != 4 {
+		t.Fatalf("expected four sessions, got %+v", report.Holders)
+	}
+	conflicts := report.Conflicts()
+	if len(conflicts) != 2 || conflicts[0].SessionID != 87 || conflicts[1].SessionID != 96 {
+		t.Fatalf("only the blocker and the table lock should conflict, got %+v", conflicts)
+	}
+	if h := conflicts[0]; !reflect.DeepEqual(h.Modes, []string{"IX", "X"}) || !h.TransactionStarted.Equal(started) || h.Blocking != 1 {
+		t.Errorf("unexpected holder: %+v", h)
+	}
+
+	call := fake.Calls()[0]
+	if call.Params["t1"] != "[dbo].[Goods Outward Header]" {
+		t.Errorf("table passed as %v", call.Params["t1"])
+	}
+}
+
+func TestCheckLocksUnsupported(t *testing.T) {
+	fake := dbtest.New()
+	fake.Dialect = database.SQLite
+
+	_, err := database.CheckLocks(context.Background(), fake, "db", []string{"orders"})
+	if !errors.Is(err, database.ErrLockCheckUnsupported) {
+		t.Errorf("err = %v", err)
+	}
+}
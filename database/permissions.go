@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Permission is one right a login needs on a table.
+type Permission struct {
+	Table string
+	Name  string
+}
+
+func (p Permission) String() string {
+	return p.Name + " on " + p.Table
+}
+
+var (
+	writeTarget = regexp.MustCompile(`(?i)\b(UPDATE|INSERT(?:\s+INTO)?|DELETE(?:\s+FROM)?|MERGE(?:\s+INTO)?)\s+(` + tableName + `)`)
+	filtered    = regexp.MustCompile(`(?i)\bWHERE\b`)
+)
+
+// StatementPermissions lists what a login needs to run statement: the
+// write on the table it changes, and SELECT on every table it reads. An
+// UPDATE or DELETE with a WHERE clause reads its own target too.
+func StatementPermissions(statement string) []Permission {
+	var needed []Permission
+
+	verb, target := statementTarget(statement)
+	switch verb {
+	case "":
+	case "MERGE":
+		needed = append(needed, Permission{target, "INSERT"}, Permission{target, "UPDATE"})
+	default:
+		needed = append(needed, Permission{target, verb})
+		if verb != "INSERT" && filtered.MatchString(stripQuoted(statement, false)) {
+			needed = append(needed, Permission{target, "SELECT"})
+		}
+	}
+
+	for _, table := range StatementTables(statement) {
+		if !strings.EqualFold(table, target) {
+			needed = append(needed, Permission{table, "SELECT"})
+		}
+	}
+
+	return needed
+}
+
+// statementTarget finds the table statement writes to and how, as one of
+// UPDATE, INSERT, DELETE or MERGE. Both are empty for reads.
+func statementTarget(statement string) (string, string) {
+	stripped := stripQuoted(statement, true)
+	keywords := stripQuoted(statement, false)
+
+	for _, loc := range writeTarget.FindAllStringSubmatchIndex(stripped, -1) {
+		if keywords[loc[2]] == ' ' {
+			continue
+		}
+		verb := strings.ToUpper(strings.Fields(stripped[loc[2]:loc[3]])[0])
+		return verb, normaliseTable(stripped[loc[4]:loc[5]])
+	}
+	return "", ""
+}
+
+// MissingPermissions returns the permissions in needed that the login
+// serverName connects as does not hold. A table it cannot see counts as
+// missing. Dialects with no way to ask, like SQLite, report none.
+func MissingPermissions(ctx context.Context, connector Connector, serverName string, needed []Permission) ([]Permission, error) {
+	if len(needed) == 0 {
+		return nil, nil
+	}
+
+	conn, err := connector.Connect(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	dialect := conn.Dialect()
+	if dialect.PermissionCheck == "" {
+		return nil, nil
+	}
+
+	params := make(map[string]interface{}, 2*len(needed))
+	checks := make([]string, len(needed))
+	for i, p := range needed {
+		table, name := fmt.Sprintf("t%d", i+1), fmt.Sprintf("p%d", i+1)
+		checks[i] = fmt.Sprintf(dialect.PermissionCheck, "@"+table, "@"+name)
+		params[table] = dialect.QuoteTable(p.Table)
+		params[name] = p.Name
+	}
+
+	rows, err := conn.Query(ctx, "SELECT "+strings.Join(checks, ", "), params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if len(rows.Values) != 1 || len(rows.Values[0]) != len(needed) {
+		return nil, fmt.Errorf("failed to check permissions: expected one row of %d values", len(needed))
+	}
+
+	var missing []Permission
+	for i, value := range rows.Values[0] {
+		if !toBool(value) {
+			missing = append(missing, needed[i])
+		}
+	}
+
+	return missing, nil
+}
+
+func toBool(v interface{}) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return toInt64(v) != 0
+}
@@ -0,0 +1,55 @@
+package database_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/database/dbtest"
+)
+
+func TestStatementPermissions(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []database.Permission
+	}{
+		{database.ShippingChange, []database.Permission{
+			{Table: "dbo.Goods Outward Header", Name: "UPDATE"},
+			{Table: "dbo.Goods Outward Header", Name: "SELECT"},
+		}},
+		{`INSERT INTO dbo.Archive SELECT * FROM dbo.Orders`, []database.Permission{
+			{Table: "dbo.Archive", Name: "INSERT"},
+			{Table: "dbo.Orders", Name: "SELECT"},
+		}},
+		{`SELECT [Update Flag] FROM Orders`, []database.Permission{
+			{Table: "Orders", Name: "SELECT"},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := database.StatementPermissions(tt.statement); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("StatementPermissions(%q) = %v, want %v", tt.statement, got, tt.want)
+		}
+	}
+}
+
+func TestMissingPermissions(t *testing.T) {
+	fake := dbtest.New()
+	fake.OnQuery("HAS_PERMS_BY_NAME", database.Rows{Values: [][]interface{}{{int64(0), int64(1)}}}, nil)
+
+	needed := database.StatementPermissions(database.ShippingChange)
+	missing, err := database.MissingPermissions(context.Background(), fake, "RKW Level 1", needed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(missing, needed[:1]) {
+		t.Errorf("missing = %v, want %v", missing, needed[:1])
+	}
+
+	fake.Reset()
+	fake.Dialect = database.SQLite
+	if missing, err := database.MissingPermissions(context.Background(), fake, "RKW Level 1", needed); err != nil || missing != nil {
+		t.Errorf("SQLite should report nothing missing, got %v, %v", missing, err)
+	}
+}
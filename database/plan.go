@@ -0,0 +1,306 @@
+package database
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Planner is implemented by connections that can return the estimated
+// plan for a statement without running it.
+type Planner interface {
+	// Plan returns the raw showplan XML for statement.
+	Plan(ctx context.Context, statement string, params map[string]interface{}) (string, error)
+}
+
+// PlanOperator is one node of the plan tree. Depth is 0 for the root.
+type PlanOperator struct {
+	Physical      string
+	Logical       string
+	Table         string
+	Index         string
+	EstimatedRows float64
+	Depth         int
+}
+
+// Scan reports whether the operator reads a whole table or index.
+func (o PlanOperator) Scan() bool {
+	switch o.Physical {
+	case "Table Scan", "Clustered Index Scan", "Index Scan":
+		return true
+	}
+	return false
+}
+
+// PlanWarning is a problem the optimiser flagged. Detail holds the
+// expression or columns involved, if any.
+type PlanWarning struct {
+	Message string
+	Detail  string
+}
+
+type MissingIndex struct {
+	Table      string
+	Impact     float64
+	Equality   []string
+	Inequality []string
+	Include    []string
+}
+
+// Plan is the estimated plan for one statement of a batch.
+type Plan struct {
+	Statement      string
+	Type           string
+	EstimatedRows  float64
+	EstimatedCost  float64
+	Operators      []PlanOperator
+	Warnings       []PlanWarning
+	MissingIndexes []MissingIndex
+}
+
+// Scans lists the tables the plan reads in full, once each.
+func (p Plan) Scans() []string {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, op := range p.Operators {
+		if op.Scan() && op.Table != "" && !seen[op.Table] {
+			seen[op.Table] = true
+			tables = append(tables, op.Table)
+		}
+	}
+	return tables
+}
+
+// ShowPlan asks serverName for the estimated plan of statement. Nothing is
+// executed.
+func ShowPlan(ctx context.Context, connector Connector, serverName, statement string, params map[string]interface{}) ([]Plan, error) {
+	conn, err := connector.Connect(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	planner, ok := conn.(Planner)
+	if !ok {
+		return nil, fmt.Errorf("query plans are not available for %s", conn.Dialect().Name)
+	}
+
+	raw, err := planner.Plan(ctx, statement, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePlan(raw)
+}
+
+// Plan runs statement under SET SHOWPLAN_XML ON. The setting belongs to the
+// session, so a single connection is held for the whole exchange.
+func (c *sqlConn) Plan(ctx context.Context, statement string, params map[string]interface{}) (string, error) {
+	if c.dialect.Name != MSSQL.Name {
+		return "", fmt.Errorf("query plans are only available on SQL Server, not %s", c.dialect.Name)
+	}
+
+	bound, args, err := c.dialect.Bind(statement, params)
+	if err != nil {
+		return "", err
+	}
+
+	session, err := c.db.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	if _, err := session.ExecContext(ctx, "SET SHOWPLAN_XML ON"); err != nil {
+		return "", fmt.Errorf("failed to enable showplan: %w", err)
+	}
+	defer session.ExecContext(context.Background(), "SET SHOWPLAN_XML OFF")
+
+	rows, err := session.QueryContext(ctx, bound, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch plan: %w", err)
+	}
+	defer rows.Close()
+
+	// each statement in the batch comes back as its own result set
+	var plan strings.Builder
+	for {
+		for rows.Next() {
+			var part string
+			if err := rows.Scan(&part); err != nil {
+				return "", fmt.Errorf("failed to read plan: %w", err)
+			}
+			plan.WriteString(part)
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	return plan.String(), nil
+}
+
+// ParsePlan reads showplan XML. Several documents may be concatenated, one
+// per statement in the batch.
+func ParsePlan(raw string) ([]Plan, error) {
+	decoder := xml.NewDecoder(strings.NewReader(raw))
+
+	var (
+		plans   []Plan
+		current *Plan
+		// ops holds the index in current.Operators of each open RelOp
+		ops     []int
+		missing *MissingIndex
+		usage   string
+		noStats bool
+	)
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return plans, fmt.Errorf("failed to parse plan: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "StmtSimple", "StmtCond", "StmtCursor":
+				plans = append(plans, Plan{
+					Statement:     strings.TrimSpace(attr(t, "StatementText")),
+					Type:          attr(t, "StatementType"),
+					EstimatedRows: floatAttr(t, "StatementEstRows"),
+					EstimatedCost: floatAttr(t, "StatementSubTreeCost"),
+				})
+				current = &plans[len(plans)-1]
+				ops = ops[:0]
+			case "RelOp":
+				if current == nil {
+					continue
+				}
+				current.Operators = append(current.Operators, PlanOperator{
+					Physical:      attr(t, "PhysicalOp"),
+					Logical:       attr(t, "LogicalOp"),
+					EstimatedRows: floatAttr(t, "EstimateRows"),
+					Depth:         len(ops),
+				})
+				ops = append(ops, len(current.Operators)-1)
+			case "Object":
+				// the first Object inside a RelOp, before any child RelOp,
+				// is the table it works on
+				if current == nil || len(ops) == 0 {
+					continue
+				}
+				op := &current.Operators[ops[len(ops)-1]]
+				if op.Table == "" {
+					op.Table = objectName(t)
+					op.Index = unbracket(attr(t, "Index"))
+				}
+			case "PlanAffectingConvert":
+				if current != nil {
+					current.Warnings = append(current.Warnings, PlanWarning{
+						Message: "Implicit conversion may affect " + strings.ToLower(attr(t, "ConvertIssue")),
+						Detail:  attr(t, "Expression"),
+					})
+				}
+			case "NoJoinPredicate":
+				if current != nil {
+					current.Warnings = append(current.Warnings, PlanWarning{Message: "Join without a join predicate"})
+				}
+			case "ColumnsWithNoStatistics":
+				if current != nil {
+					current.Warnings = append(current.Warnings, PlanWarning{Message: "Columns without statistics"})
+					noStats = true
+				}
+			case "SpillToTempDb":
+				if current != nil {
+					current.Warnings = append(current.Warnings, PlanWarning{Message: "Operator spills to tempdb"})
+				}
+			case "MissingIndexGroup":
+				if current != nil {
+					current.MissingIndexes = append(current.MissingIndexes, MissingIndex{Impact: floatAttr(t, "Impact")})
+					missing = &current.MissingIndexes[len(current.MissingIndexes)-1]
+				}
+			case "MissingIndex":
+				if missing != nil {
+					missing.Table = objectName(t)
+				}
+			case "ColumnGroup":
+				usage = attr(t, "Usage")
+			case "Column":
+				if missing == nil {
+					continue
+				}
+				name := unbracket(attr(t, "Name"))
+				switch usage {
+				case "EQUALITY":
+					missing.Equality = append(missing.Equality, name)
+				case "INEQUALITY":
+					missing.Inequality = append(missing.Inequality, name)
+				case "INCLUDE":
+					missing.Include = append(missing.Include, name)
+				}
+			case "ColumnReference":
+				if noStats {
+					warning := &current.Warnings[len(current.Warnings)-1]
+					warning.Detail = strings.TrimPrefix(warning.Detail+", "+unbracket(attr(t, "Column")), ", ")
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "RelOp":
+				if len(ops) > 0 {
+					ops = ops[:len(ops)-1]
+				}
+			case "ColumnsWithNoStatistics":
+				noStats = false
+			case "MissingIndexGroup":
+				missing = nil
+			case "ColumnGroup":
+				usage = ""
+			}
+		}
+	}
+
+	if len(plans) == 0 {
+		return nil, errors.New("failed to parse plan: no statements found")
+	}
+
+	return plans, nil
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func floatAttr(e xml.StartElement, name string) float64 {
+	f, _ := strconv.ParseFloat(attr(e, name), 64)
+	return f
+}
+
+// objectName gives schema.table without the brackets showplan adds.
+func objectName(e xml.StartElement) string {
+	table := unbracket(attr(e, "Table"))
+	if schema := unbracket(attr(e, "Schema")); schema != "" && table != "" {
+		return schema + "." + table
+	}
+	return table
+}
+
+func unbracket(name string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParsePlan(t *testing.T) {
+	raw, err := os.ReadFile("testdata/showplan_shipping.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plans, err := ParsePlan(string(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("expected one statement, got %d", len(plans))
+	}
+
+	plan := plans[0]
+	if plan.Type != "UPDATE" || plan.EstimatedRows != 1.6 {
+		t.Errorf("statement = %s, %v rows", plan.Type, plan.EstimatedRows)
+	}
+
+	want := []PlanOperator{
+		{Physical: "Clustered Index Update", Logical: "Update", Table: "dbo.Goods Outward Header", Index: "Goods Outward Header$0", EstimatedRows: 1.6},
+		{Physical: "Top", Logical: "Top", EstimatedRows: 1.6, Depth: 1},
+		{Physical: "Clustered Index Scan", Logical: "Clustered Index Scan", Table: "dbo.Goods Outward Header", Index: "Goods Outward Header$0", EstimatedRows: 1.6, Depth: 2},
+	}
+	if !reflect.DeepEqual(plan.Operators, want) {
+		t.Errorf("operators:\n got %+v\nwant %+v", plan.Operators, want)
+	}
+
+	if scans := plan.Scans(); !reflect.DeepEqual(scans, []string{"dbo.Goods Outward Header"}) {
+		t.Errorf("scans = %v", scans)
+	}
+
+	wantWarnings := []PlanWarning{{
+		Message: "Implicit conversion may affect seek plan",
+		Detail:  "CONVERT_IMPLICIT(nvarchar(20),[NAV].[dbo].[Goods Outward Header].[Sales Order No_],0)=[@OrderNo]",
+	}}
+	if !reflect.DeepEqual(plan.Warnings, wantWarnings) {
+		t.Errorf("warnings = %+v", plan.Warnings)
+	}
+
+	wantIndex := []MissingIndex{{Table: "dbo.Goods Outward Header", Impact: 99.12, Equality: []string{"Sales Order No_"}}}
+	if !reflect.DeepEqual(plan.MissingIndexes, wantIndex) {
+		t.Errorf("missing indexes = %+v", plan.MissingIndexes)
+	}
+}
+
+func TestParsePlanRejectsEmptyPlans(t *testing.T) {
+	if _, err := ParsePlan(""); err == nil {
+		t.Error("expected an error for an empty plan")
+	}
+}
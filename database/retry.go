@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+)
+
+// RetryPolicy says how often a statement that failed with a transient
+// error is tried again. Each attempt reconnects and runs in a fresh
+// transaction, so a deadlock victim is simply replayed.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var (
+	DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 250 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+	// NoRetry is for statements that must not run twice, e.g. ones that
+	// are not idempotent and may have committed before the error.
+	NoRetry = RetryPolicy{MaxAttempts: 1}
+)
+
+// mssqlTransient are SQL Server errors that clear up on their own: deadlocks,
+// lock timeouts, failovers and throttling.
+var mssqlTransient = map[int32]bool{
+	1205:  true, // chosen as deadlock victim
+	1222:  true, // lock request timeout
+	233:   true, // connection broken
+	64:    true, // network name no longer available
+	10053: true, // connection aborted
+	10054: true, // connection reset by peer
+	10060: true, // connection timed out
+	4060:  true, // database unavailable, usually mid-failover
+	4221:  true, // read-only replica not ready
+	40143: true, // failover in progress
+	40197: true, // service error processing request
+	40501: true, // service busy
+	40613: true, // database unavailable
+	49918: true, // not enough resources
+	49919: true, // too many operations
+	49920: true, // too many operations
+}
+
+// IsTransient reports whether err is worth retrying. Anything it does not
+// recognise is treated as permanent, as is cancellation by the user.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var msErr mssql.Error
+	if errors.As(err, &msErr) {
+		return mssqlTransient[msErr.Number]
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// serialization failure, deadlock, and the connection exception class
+		return pgErr.Code == "40001" || pgErr.Code == "40P01" || strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1213 || myErr.Number == 1205
+	}
+
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) {
+		code := liteErr.Code() & 0xff
+		return code == 5 || code == 6 // SQLITE_BUSY, SQLITE_LOCKED
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isLockTimeout reports whether err is the server giving up on a lock
+// because the lock timeout ran out.
+func isLockTimeout(err error) bool {
+	var msErr mssql.Error
+	if errors.As(err, &msErr) {
+		return msErr.Number == 1222
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "55P03"
+	}
+
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == 1205
+}
+
+// delay is the wait before retry n (counting from 1): exponential backoff
+// capped at MaxDelay, with jitter so clients that collided do not collide
+// again.
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// ExecResult is the outcome of ExecWithRetry. Retries counts the attempts
+// after the first, whether or not the statement finally succeeded, and
+// Connected is set once any attempt reached the server.
+type ExecResult struct {
+	Rows      int64
+	Params    string
+	Retries   int
+	Connected bool
+}
+
+// ExecWithRetry connects to serverName and runs statement, retrying
+// transient failures under policy. onRetry, if set, is told about each
+// failure before the wait.
+func ExecWithRetry(ctx context.Context, connector Connector, serverName, statement string, params map[string]interface{}, policy RetryPolicy, onRetry func(attempt int, err error, wait time.Duration)) (ExecResult, error) {
+	attempts := max(policy.MaxAttempts, 1)
+
+	var result ExecResult
+	for attempt := 1; ; attempt++ {
+		rows, debugInfo, connected, err := execOnce(ctx, connector, serverName, statement, params)
+		result.Rows, result.Params = rows, debugInfo
+		result.Connected = result.Connected || connected
+		if err == nil || attempt >= attempts || !IsTransient(err) {
+			return result, err
+		}
+		// the caller asked not to wait on locks, so waiting again defeats it
+		if _, ok := LockTimeout(ctx); ok && isLockTimeout(err) {
+			return result, err
+		}
+
+		wait := policy.delay(attempt)
+		if onRetry != nil {
+			onRetry(attempt, err, wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(wait):
+		}
+		result.Retries++
+	}
+}
+
+func execOnce(ctx context.Context, connector Connector, serverName, statement string, params map[string]interface{}) (int64, string, bool, error) {
+	conn, err := connector.Connect(ctx, serverName)
+	if err != nil {
+		return 0, formatParams(params), false, err
+	}
+	defer conn.Close()
+
+	rows, debugInfo, err := conn.Exec(ctx, statement, params)
+	return rows, debugInfo, true, err
+}
@@ -0,0 +1,142 @@
+package database_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/database/dbtest"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{mssql.Error{Number: 1205, Message: "deadlock victim"}, true},
+		{fmt.Errorf("wrapped: %w", mssql.Error{Number: 40613}), true},
+		{mssql.Error{Number: 2627, Message: "duplicate key"}, false},
+		{mssql.Error{Number: 18456, Message: "login failed"}, false},
+		{&pgconn.PgError{Code: "40P01"}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{driver.ErrBadConn, true},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("syntax error"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := database.IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+var fastRetry = database.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+func TestExecWithRetryRecoversFromDeadlock(t *testing.T) {
+	fake := dbtest.New().
+		OnExec("UPDATE", 0, mssql.Error{Number: 1205}).
+		OnExec("UPDATE", 4, nil)
+
+	var retried []int
+	result, err := database.ExecWithRetry(context.Background(), fake, "db", "UPDATE t SET a = 1", nil, fastRetry,
+		func(attempt int, err error, wait time.Duration) { retried = append(retried, attempt) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 4 || result.Retries != 1 || !result.Connected {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(retried) != 1 || retried[0] != 1 {
+		t.Errorf("onRetry calls: %v", retried)
+	}
+	if len(fake.Execs()) != 2 {
+		t.Errorf("expected two attempts, got %d", len(fake.Execs()))
+	}
+}
+
+func TestExecWithRetryGivesUp(t *testing.T) {
+	fake := dbtest.New().OnExec("UPDATE", 0, mssql.Error{Number: 1205})
+
+	result, err := database.ExecWithRetry(context.Background(), fake, "db", "UPDATE t SET a = 1", nil, fastRetry, nil)
+	if err == nil {
+		t.Fatal("expected the deadlock to be returned")
+	}
+	if result.Retries != 2 || len(fake.Execs()) != 3 {
+		t.Errorf("retries = %d, attempts = %d", result.Retries, len(fake.Execs()))
+	}
+}
+
+func TestExecWithRetrySkipsPermanentErrors(t *testing.T) {
+	fake := dbtest.New().OnExec("UPDATE", 0, mssql.Error{Number: 2627})
+
+	result, err := database.ExecWithRetry(context.Background(), fake, "db", "UPDATE t SET a = 1", nil, fastRetry, nil)
+	if err == nil || result.Retries != 0 || len(fake.Execs()) != 1 {
+		t.Errorf("permanent errors should not be retried: %+v, %v", result, err)
+	}
+}
+
+func TestExecWithRetryGivesUpOnLockTimeouts(t *testing.T) {
+	timeouts := []error{
+		mssql.Error{Number: 1222, Message: "lock request time out period exceeded"},
+		&pgconn.PgError{Code: "55P03"},
+		&mysql.MySQLError{Number: 1205, Message: "lock wait timeout exceeded"},
+	}
+
+	for _, timeout := range timeouts {
+		fake := dbtest.New().OnExec("UPDATE", 0, timeout)
+		ctx := database.WithLockTimeout(context.Background(), time.Second)
+
+		result, err := database.ExecWithRetry(ctx, fake, "db", "UPDATE t SET a = 1", nil, fastRetry, nil)
+		if err == nil || result.Retries != 0 || len(fake.Execs()) != 1 {
+			t.Errorf("%v with a lock timeout: %+v after %d attempts", timeout, result, len(fake.Execs()))
+		}
+	}
+
+	// without a lock timeout the server's own timeout is still worth retrying
+	fake := dbtest.New().OnExec("UPDATE", 0, mssql.Error{Number: 1222})
+	database.ExecWithRetry(context.Background(), fake, "db", "UPDATE t SET a = 1", nil, fastRetry, nil)
+	if len(fake.Execs()) != 3 {
+		t.Errorf("expected 1222 to be retried without a lock timeout, got %d attempts", len(fake.Execs()))
+	}
+}
+
+func TestExecWithRetryNoRetry(t *testing.T) {
+	fake := dbtest.New().OnExec("UPDATE", 0, mssql.Error{Number: 1205})
+
+	database.ExecWithRetry(context.Background(), fake, "db", "UPDATE t SET a = 1", nil, database.NoRetry, nil)
+	if len(fake.Execs()) != 1 {
+		t.Errorf("NoRetry ran %d attempts", len(fake.Execs()))
+	}
+}
+
+func TestExecWithRetryReconnects(t *testing.T) {
+	fake := dbtest.New().FailConnect("db", driver.ErrBadConn)
+
+	result, err := database.ExecWithRetry(context.Background(), fake, "db", "UPDATE t SET a = 1", nil, fastRetry, nil)
+	if err == nil || result.Connected || result.Retries != 2 {
+		t.Errorf("unexpected result: %+v, %v", result, err)
+	}
+}
+
+func TestExecWithRetryStopsWhenCancelled(t *testing.T) {
+	fake := dbtest.New().OnExec("UPDATE", 0, mssql.Error{Number: 1205})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	slow := database.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	_, err := database.ExecWithRetry(ctx, fake, "db", "UPDATE t SET a = 1", nil, slow,
+		func(int, error, time.Duration) { cancel() })
+	if err == nil || len(fake.Execs()) != 1 {
+		t.Errorf("expected to stop after cancelling, got %d attempts", len(fake.Execs()))
+	}
+}
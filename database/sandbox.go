@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robertgouveia/do-my-job/storage"
+)
+
+// sandboxSchema mirrors the columns the scripts touch on the live servers.
+// SQLite accepts the same [bracketed] names, so statements run unchanged.
+var sandboxSchema = []string{
+	`CREATE TABLE [DeliveryIssuesHead] (
+		[IssueID]      INTEGER PRIMARY KEY,
+		[CustomerNo]   TEXT NOT NULL,
+		[OrderNo]      TEXT NOT NULL,
+		[Status]       TEXT NOT NULL,
+		[Reason]       TEXT,
+		[LoggedAt]     TEXT NOT NULL
+	)`,
+	`CREATE TABLE [Goods Outward Header] (
+		[Sales Order No_]        TEXT PRIMARY KEY,
+		[Customer No_]           TEXT NOT NULL,
+		[Shipping Agent Code]    TEXT NOT NULL,
+		[Shipping Agent Service] TEXT NOT NULL,
+		[Shipment Date]          TEXT NOT NULL
+	)`,
+}
+
+var sandboxSeed = []string{
+	`INSERT INTO [DeliveryIssuesHead] VALUES
+		(1001, 'C0042', 'SO-50001', 'Logged', 'Damaged in transit', '2024-03-01 09:12'),
+		(1002, 'C0042', 'SO-50002', 'Investigating', 'Short delivery', '2024-03-02 14:30'),
+		(1003, 'C0107', 'SO-50013', 'Investigation Approved', 'Wrong item', '2024-03-04 11:05'),
+		(1004, 'C0233', 'SO-50021', 'Sent To Accounts Approved', 'Damaged in transit', '2024-03-05 16:47'),
+		(1005, 'C0311', 'SO-50034', 'Awaiting RAN', 'Customer refused', '2024-03-07 08:20'),
+		(1006, 'C0107', 'SO-50040', 'Disputed', 'Late delivery', '2024-03-08 10:55')`,
+	`INSERT INTO [Goods Outward Header] VALUES
+		('SO-50001', 'C0042', 'DPD', '24', '2024-02-28'),
+		('SO-50002', 'C0042', 'DPD', '24', '2024-02-29'),
+		('SO-50013', 'C0107', 'UPS', '72', '2024-03-01'),
+		('SO-50021', 'C0233', 'DPD', '48', '2024-03-02'),
+		('SO-50034', 'C0311', 'PALLETWAYS', 'ECONOMY', '2024-03-04'),
+		('SO-50040', 'C0107', 'UPS', '24', '2024-03-05')`,
+}
+
+// SandboxTables lists the seeded tables, for browsing the practice data.
+var SandboxTables = []string{"DeliveryIssuesHead", "Goods Outward Header"}
+
+// Sandbox answers for every server with one local SQLite file, so scripts
+// can be practised without touching a real database.
+type Sandbox struct {
+	Path string
+}
+
+// OpenSandbox creates and seeds the sandbox file at path if it does not
+// exist yet.
+func OpenSandbox(path string) (*Sandbox, error) {
+	s := &Sandbox{Path: path}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := s.seed(); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open sandbox: %w", err)
+	}
+
+	return s, nil
+}
+
+// Reset throws away any practice changes and reseeds the sample data.
+func (s *Sandbox) Reset() error {
+	if err := os.Remove(s.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove sandbox: %w", err)
+	}
+	return s.seed()
+}
+
+func (s *Sandbox) seed() error {
+	tmp := s.Path + ".tmp"
+	os.Remove(tmp)
+
+	db, err := sql.Open(SQLite.Driver, SQLite.DSN(sandboxConfig(tmp), ""))
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox: %w", err)
+	}
+
+	for _, stmt := range append(append([]string{}, sandboxSchema...), sandboxSeed...) {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("failed to seed sandbox: %w", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to seed sandbox: %w", err)
+	}
+
+	// seed into a temp file so a half-built sandbox is never picked up
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	return nil
+}
+
+// Connect ignores serverName: every server is the sandbox.
+func (s *Sandbox) Connect(ctx context.Context, serverName string) (Conn, error) {
+	base, err := sql.Open(SQLite.Driver, ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sandbox: %w", err)
+	}
+	drv := base.Driver()
+	base.Close()
+
+	db := sql.OpenDB(sandboxConnector{driver: drv, path: s.Path})
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sandbox: %w -- file: %s", err, s.Path)
+	}
+
+	return &sqlConn{db: db, dialect: SQLite}, nil
+}
+
+func sandboxConfig(path string) storage.ServerConfig {
+	return storage.ServerConfig{Host: path, Dialect: SQLite.Name}
+}
+
+// sandboxConnector attaches the sandbox file as "dbo" on every new
+// connection. The scripts name their tables [dbo].[...], and SQLite also
+// finds unqualified names in attached databases, so both forms work.
+type sandboxConnector struct {
+	driver driver.Driver
+	path   string
+}
+
+func (c sandboxConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(":memory:")
+	if err != nil {
+		return nil, err
+	}
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, errors.New("sqlite driver cannot execute statements")
+	}
+
+	for _, stmt := range []string{
+		"ATTACH DATABASE '" + strings.ReplaceAll(c.path, "'", "''") + "' AS dbo",
+		SQLite.ResetLockTimeout,
+	} {
+		if _, err := execer.ExecContext(ctx, stmt, nil); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (c sandboxConnector) Driver() driver.Driver {
+	return c.driver
+}
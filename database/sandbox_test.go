@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSandboxRunsScripts(t *testing.T) {
+	ctx := context.Background()
+	sandbox, err := OpenSandbox(filepath.Join(t.TempDir(), "sandbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := sandbox.Connect(ctx, "RKW Data Warehouse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rows, _, err := conn.Exec(ctx, DisputeChange, map[string]interface{}{"IssueID": 1002, "Status": "Cancelled"})
+	if err != nil || rows != 1 {
+		t.Fatalf("dispute change: %d rows, %v", rows, err)
+	}
+	rows, _, err = conn.Exec(ctx, ShippingChange, map[string]interface{}{"OrderNo": "SO-50001"})
+	if err != nil || rows != 1 {
+		t.Fatalf("shipping change: %d rows, %v", rows, err)
+	}
+
+	status := func() interface{} {
+		result, err := conn.Query(ctx, `SELECT [Status] FROM [DeliveryIssuesHead] WHERE [IssueID] = @ID`, map[string]interface{}{"ID": 1002})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Values[0][0]
+	}
+	if got := status(); got != "Cancelled" {
+		t.Errorf("status = %v after the script", got)
+	}
+
+	if err := sandbox.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	conn, err = sandbox.Connect(ctx, "RKW Level 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != "Investigating" {
+		t.Errorf("status = %v after reset, want the seeded value", got)
+	}
+}
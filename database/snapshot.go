@@ -0,0 +1,429 @@
+package database
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const snapshotTimeFormat = "20060102-150405.000"
+
+// SnapshotSpec picks the rows a script backs up before it runs.
+type SnapshotSpec struct {
+	// Where filters the rows, using the script's @params. Empty takes the
+	// rows the statement's own WHERE clause picks.
+	Where string
+	// Key identifies a row when merging the snapshot back. Empty uses the
+	// table's primary key.
+	Key []string
+}
+
+// Snapshot is a copy of some rows of one table, along with the columns
+// needed to put them back.
+type Snapshot struct {
+	Server string
+	Table  string
+	// Verb is the statement the snapshot was taken before, such as UPDATE
+	// or DELETE.
+	Verb   string
+	Where  string
+	Params map[string]interface{}
+	Key    []string
+	Taken  time.Time
+	// Sandbox is set for snapshots taken in training mode.
+	Sandbox bool
+	Columns []Column
+	Rows    [][]interface{}
+}
+
+// Restorer is implemented by connections that can put a snapshot back in
+// one transaction.
+type Restorer interface {
+	// Restore inserts the snapshot's rows, or with merge updates the rows
+	// that still exist by key and inserts the rest.
+	Restore(ctx context.Context, snapshot Snapshot, merge bool) (int64, error)
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// snapshotWhere works out the verb of statement, the table spec backs up
+// for it and the WHERE clause that picks its rows.
+func snapshotWhere(statement string, spec SnapshotSpec) (string, string, string, error) {
+	verb, table := statementTarget(statement)
+	if table == "" {
+		return "", "", "", errors.New("the statement does not change a table")
+	}
+	if spec.Where != "" {
+		return verb, table, spec.Where, nil
+	}
+
+	if verb == "INSERT" || verb == "MERGE" {
+		return "", "", "", fmt.Errorf("the rows a %s changes cannot be worked out, set Where", verb)
+	}
+	if len(StatementTables(statement)) > 1 {
+		return "", "", "", errors.New("the statement reads other tables, so its rows cannot be worked out, set Where")
+	}
+
+	loc := filtered.FindStringIndex(stripQuoted(statement, false))
+	if loc == nil {
+		return "", "", "", errors.New("the statement changes the whole table, set Where to back it up")
+	}
+	return verb, table, strings.TrimRight(strings.TrimSpace(statement[loc[1]:]), ";"), nil
+}
+
+// primaryKey returns the primary key columns of table, or none where the
+// table has no primary key or the dialect cannot list it.
+func primaryKey(ctx context.Context, conn Conn, schema, table string) ([]string, error) {
+	query := conn.Dialect().KeyQuery
+	if query == "" {
+		return nil, nil
+	}
+
+	rows, err := conn.Query(ctx, query, map[string]interface{}{"schema": schema, "table": table})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the primary key: %w", err)
+	}
+
+	var key []string
+	for _, row := range rows.Values {
+		if len(row) > 0 {
+			key = append(key, toString(row[0]))
+		}
+	}
+	return key, nil
+}
+
+// TakeSnapshot reads the rows of the table statement changes that spec
+// picks, before the statement is run with params.
+func TakeSnapshot(ctx context.Context, connector Connector, serverName, statement string, spec SnapshotSpec, params map[string]interface{}) (Snapshot, error) {
+	verb, table, where, err := snapshotWhere(statement, spec)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	columns, err := Columns(ctx, connector, serverName, []string{table})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	columns = tableColumns(columns, table)
+	if len(columns) == 0 {
+		return Snapshot{}, fmt.Errorf("table %s does not exist", table)
+	}
+	if columns[0].Schema != "" {
+		table = columns[0].Schema + "." + columns[0].Table
+	}
+
+	conn, err := connector.Connect(ctx, serverName)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer conn.Close()
+
+	// the columns a WHERE clause compares need not pick one row each, so
+	// only a primary key is trusted to merge on
+	key := spec.Key
+	if len(key) == 0 {
+		if key, err = primaryKey(ctx, conn, columns[0].Schema, columns[0].Table); err != nil {
+			return Snapshot{}, err
+		}
+	}
+
+	dialect := conn.Dialect()
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = dialect.Quote(c.Name)
+	}
+
+	// Bind passes on only the params the WHERE clause uses
+	rows, err := conn.Query(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		strings.Join(names, ", "), dialect.QuoteTable(table), where), params)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return Snapshot{
+		Server:  serverName,
+		Table:   table,
+		Verb:    verb,
+		Where:   where,
+		Params:  params,
+		Key:     key,
+		Taken:   time.Now().UTC(),
+		Columns: columns,
+		Rows:    rows.Values,
+	}, nil
+}
+
+// snapshotFile is the snapshot as saved. Values are kept as text, or null,
+// and read back using the column types, as JSON numbers would lose the
+// difference between integers and decimals.
+type snapshotFile struct {
+	Server  string                 `json:"server"`
+	Table   string                 `json:"table"`
+	Verb    string                 `json:"verb,omitempty"`
+	Where   string                 `json:"where"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	Key     []string               `json:"key,omitempty"`
+	Taken   time.Time              `json:"taken"`
+	Sandbox bool                   `json:"sandbox,omitempty"`
+	Columns []Column               `json:"columns"`
+	Rows    [][]*string            `json:"rows"`
+}
+
+// Save writes the snapshot to a gzipped JSON file in dir, named after when
+// it was taken, and returns the path.
+func (s Snapshot) Save(dir string) (string, error) {
+	file := snapshotFile{
+		Server:  s.Server,
+		Table:   s.Table,
+		Verb:    s.Verb,
+		Where:   s.Where,
+		Params:  s.Params,
+		Key:     s.Key,
+		Taken:   s.Taken,
+		Sandbox: s.Sandbox,
+		Columns: s.Columns,
+		Rows:    make([][]*string, len(s.Rows)),
+	}
+	for i, row := range s.Rows {
+		file.Rows[i] = make([]*string, len(row))
+		for j, value := range row {
+			file.Rows[i][j] = encodeValue(s.Columns[j], value)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s_%s_%s.json.gz", s.Taken.UTC().Format(snapshotTimeFormat),
+		strings.Trim(unsafeFileChars.ReplaceAllString(s.Server, "-"), "-"),
+		strings.Trim(unsafeFileChars.ReplaceAllString(s.Table, "-"), "-"))
+	path := filepath.Join(dir, name)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(file); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return path, nil
+}
+
+func LoadSnapshot(path string) (Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	defer zr.Close()
+
+	var file snapshotFile
+	if err := json.NewDecoder(zr).Decode(&file); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	s := Snapshot{
+		Server:  file.Server,
+		Table:   file.Table,
+		Verb:    file.Verb,
+		Where:   file.Where,
+		Params:  file.Params,
+		Key:     file.Key,
+		Taken:   file.Taken,
+		Sandbox: file.Sandbox,
+		Columns: file.Columns,
+		Rows:    make([][]interface{}, len(file.Rows)),
+	}
+	for i, row := range file.Rows {
+		if len(row) != len(s.Columns) {
+			return Snapshot{}, fmt.Errorf("failed to read snapshot: row %d has %d values for %d columns", i+1, len(row), len(s.Columns))
+		}
+		s.Rows[i] = make([]interface{}, len(row))
+		for j, text := range row {
+			if s.Rows[i][j], err = decodeValue(s.Columns[j], text); err != nil {
+				return Snapshot{}, fmt.Errorf("failed to read snapshot: row %d: %w", i+1, err)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+func encodeValue(c Column, value interface{}) *string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		text = v.Format(time.RFC3339Nano)
+	case []byte:
+		text = base64.StdEncoding.EncodeToString(v)
+	case float64:
+		text = strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		text = fmt.Sprint(v)
+		if c.Kind() == KindBinary {
+			text = base64.StdEncoding.EncodeToString([]byte(text))
+		}
+	}
+	return &text
+}
+
+func decodeValue(c Column, text *string) (interface{}, error) {
+	t := strings.ToLower(c.DataType)
+	switch {
+	case text == nil:
+		return nil, nil
+	case c.Kind() == KindBinary:
+		return base64.StdEncoding.DecodeString(*text)
+	case c.Kind() == KindNumber && !strings.Contains(t, "int") && t != "bit":
+		// decimals stay as text so the server converts them exactly
+		return *text, nil
+	case c.Kind() == KindText, c.Kind() == KindOther:
+		return *text, nil
+	}
+	return c.Convert(*text)
+}
+
+// MergeByDefault reports whether the snapshot's rows were changed rather
+// than removed, so are still there and have to be merged back.
+func (s Snapshot) MergeByDefault() bool {
+	return s.Verb == "UPDATE"
+}
+
+// RestoreSnapshot puts the snapshot back on the server it was taken from.
+// Without merge every row is inserted, which suits rows that were deleted;
+// with merge, rows that still exist are updated by the snapshot's key.
+// Snapshots taken before an UPDATE are always merged, as inserting the
+// rows again would duplicate them.
+func RestoreSnapshot(ctx context.Context, connector Connector, snapshot Snapshot, merge bool) (int64, error) {
+	merge = merge || snapshot.MergeByDefault()
+	if merge && len(snapshot.Key) == 0 {
+		if snapshot.MergeByDefault() {
+			return 0, fmt.Errorf("%s has no primary key to merge the updated rows back on, and inserting them would duplicate them", snapshot.Table)
+		}
+		return 0, errors.New("the snapshot has no key columns to merge on, restore it without merging")
+	}
+
+	conn, err := connector.Connect(ctx, snapshot.Server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	restorer, ok := conn.(Restorer)
+	if !ok {
+		return 0, fmt.Errorf("snapshots cannot be restored on %s", conn.Dialect().Name)
+	}
+	return restorer.Restore(ctx, snapshot, merge)
+}
+
+// Restore runs one statement per row in a single transaction, so a
+// restore that fails part way leaves the table as it was. Generated
+// columns are left for the server to fill, though a generated key is
+// still used to find the rows to merge.
+func (c *sqlConn) Restore(ctx context.Context, snapshot Snapshot, merge bool) (int64, error) {
+	isKey := make(map[string]bool)
+	for _, k := range snapshot.Key {
+		isKey[strings.ToLower(k)] = true
+	}
+
+	var columns, values, sets, keys []string
+	for i, col := range snapshot.Columns {
+		name, param := c.dialect.Quote(col.Name), fmt.Sprintf("@c%d", i+1)
+		switch {
+		case isKey[strings.ToLower(col.Name)]:
+			keys = append(keys, name+" = "+param)
+		case !col.Generated:
+			sets = append(sets, name+" = "+param)
+		}
+		if !col.Generated {
+			columns = append(columns, name)
+			values = append(values, param)
+		}
+	}
+	if merge && (len(keys) != len(snapshot.Key) || len(sets) == 0) {
+		return 0, fmt.Errorf("cannot merge on %s, restore without merging", strings.Join(snapshot.Key, ", "))
+	}
+
+	table := c.dialect.QuoteTable(snapshot.Table)
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+	update := fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(sets, ", "), strings.Join(keys, " AND "))
+
+	tx, err := c.db.BeginTx(ctx, &c.dialect.TxOptions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	exec := func(statement string, params map[string]interface{}) (int64, error) {
+		bound, args, err := c.dialect.Bind(statement, params)
+		if err != nil {
+			return 0, err
+		}
+		res, err := tx.ExecContext(ctx, bound, args...)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+
+	var restored int64
+	params := make(map[string]interface{}, len(snapshot.Columns))
+	for i, row := range snapshot.Rows {
+		for j, value := range row {
+			params[fmt.Sprintf("c%d", j+1)] = value
+		}
+
+		if merge {
+			updated, err := exec(update, params)
+			if err != nil {
+				return 0, fmt.Errorf("failed to update row %d: %w", i+1, err)
+			}
+			if updated > 0 {
+				restored += updated
+				continue
+			}
+		}
+
+		if _, err := exec(insert, params); err != nil {
+			return 0, fmt.Errorf("failed to insert row %d: %w", i+1, err)
+		}
+		restored++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return restored, nil
+}
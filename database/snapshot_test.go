@@ -0,0 +1,225 @@
+package database_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/robertgouveia/do-my-job/database"
+)
+
+// TestSnapshotRoundTrip backs up a row in the sandbox, changes and then
+// deletes it, and restores it each time.
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	sandbox, err := database.OpenSandbox(filepath.Join(dir, "sandbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	params := map[string]interface{}{"OrderNo": "SO-50001"}
+
+	snapshot, err := database.TakeSnapshot(ctx, sandbox, "RKW Level 1", database.ShippingChange, database.SnapshotSpec{}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Verb != "UPDATE" || snapshot.Where != "[Sales Order No_] = @OrderNo" || !reflect.DeepEqual(snapshot.Key, []string{"Sales Order No_"}) || len(snapshot.Rows) != 1 {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	path, err := snapshot.Save(filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := database.LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Rows, snapshot.Rows) || loaded.Table != "dbo.Goods Outward Header" || loaded.Verb != "UPDATE" {
+		t.Fatalf("loaded %+v, saved %+v", loaded, snapshot)
+	}
+
+	service := func() []interface{} {
+		t.Helper()
+		conn, err := sandbox.Connect(ctx, "any")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		rows, err := conn.Query(ctx, `SELECT [Shipping Agent Service] FROM [Goods Outward Header] WHERE [Sales Order No_] = @OrderNo`, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var services []interface{}
+		for _, row := range rows.Values {
+			services = append(services, row[0])
+		}
+		return services
+	}
+	run := func(statement string) {
+		t.Helper()
+		conn, err := sandbox.Connect(ctx, "any")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, _, err := conn.Exec(ctx, statement, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run(database.ShippingChange)
+	if restored, err := database.RestoreSnapshot(ctx, sandbox, loaded, true); err != nil || restored != 1 {
+		t.Fatalf("merge restored %d rows: %v", restored, err)
+	}
+	if got := service(); !reflect.DeepEqual(got, []interface{}{"24"}) {
+		t.Errorf("after merging, service = %v", got)
+	}
+
+	// the rows of an UPDATE are still there, so are merged either way
+	if restored, err := database.RestoreSnapshot(ctx, sandbox, loaded, false); err != nil || restored != 1 {
+		t.Fatalf("plain restore of an UPDATE snapshot restored %d rows: %v", restored, err)
+	}
+	if got := service(); !reflect.DeepEqual(got, []interface{}{"24"}) {
+		t.Errorf("after restoring again, service = %v", got)
+	}
+
+	run(`DELETE FROM [dbo].[Goods Outward Header] WHERE [Sales Order No_] = @OrderNo`)
+	if restored, err := database.RestoreSnapshot(ctx, sandbox, loaded, false); err != nil || restored != 1 {
+		t.Fatalf("insert restored %d rows: %v", restored, err)
+	}
+	if got := service(); !reflect.DeepEqual(got, []interface{}{"24"}) {
+		t.Errorf("after re-inserting, service = %v", got)
+	}
+}
+
+// TestRestoreSkipsGeneratedColumns restores rows with a rowversion and a
+// computed column, as SQL Server reports them, which the server refuses
+// to have written.
+func TestRestoreSkipsGeneratedColumns(t *testing.T) {
+	dir := t.TempDir()
+	sandbox, err := database.OpenSandbox(filepath.Join(dir, "sandbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	conn, err := sandbox.Connect(ctx, "any")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _, err = conn.Exec(ctx, `CREATE TABLE [dbo].[Stock] ([Item No_] TEXT PRIMARY KEY, [Quantity] INTEGER,
+		[Doubled] INTEGER GENERATED ALWAYS AS ([Quantity] * 2), [Version] rowversion)`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := []byte{0, 0, 0, 0, 0, 0, 0x07, 0xd1}
+	snapshot := database.Snapshot{
+		Server: "any",
+		Table:  "dbo.Stock",
+		Key:    []string{"Item No_"},
+		Columns: []database.Column{
+			{Name: "Item No_", DataType: "nvarchar"},
+			{Name: "Quantity", DataType: "int"},
+			{Name: "Doubled", DataType: "int", Generated: true},
+			{Name: "Version", DataType: "rowversion", Generated: true},
+		},
+		Rows: [][]interface{}{{"1000", int64(4), int64(8), version}},
+	}
+
+	path, err := snapshot.Save(filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := database.LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Rows, snapshot.Rows) {
+		t.Fatalf("loaded %v, saved %v", loaded.Rows, snapshot.Rows)
+	}
+
+	for _, merge := range []bool{false, true} {
+		if restored, err := database.RestoreSnapshot(ctx, sandbox, loaded, merge); err != nil || restored != 1 {
+			t.Fatalf("restore (merge %v) restored %d rows: %v", merge, restored, err)
+		}
+	}
+
+	rows, err := conn.Query(ctx, `SELECT [Doubled], [Version] FROM [dbo].[Stock]`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]interface{}{{int64(8), nil}}; !reflect.DeepEqual(rows.Values, want) {
+		t.Errorf("got %v, want the generated columns left to the server", rows.Values)
+	}
+}
+
+func TestSnapshotNeedsWhereForJoins(t *testing.T) {
+	sandbox, err := database.OpenSandbox(filepath.Join(t.TempDir(), "sandbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement := `UPDATE h SET [Status] = 'Cancelled' FROM [dbo].[DeliveryIssuesHead] h JOIN [dbo].[Goods Outward Header] g ON g.[Sales Order No_] = h.OrderNo`
+	if _, err := database.TakeSnapshot(context.Background(), sandbox, "any", statement, database.SnapshotSpec{}, nil); err == nil {
+		t.Error("the rows of a joined UPDATE cannot be worked out without Where")
+	}
+}
+
+// TestSnapshotKeyIsThePrimaryKey merges on the table's primary key rather
+// than the columns the WHERE clause compares, which can match many rows,
+// and refuses to merge an UPDATE back into a table without one.
+func TestSnapshotKeyIsThePrimaryKey(t *testing.T) {
+	sandbox, err := database.OpenSandbox(filepath.Join(t.TempDir(), "sandbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	params := map[string]interface{}{"OrderNo": "SO-50001"}
+
+	statement := `UPDATE [dbo].[DeliveryIssuesHead] SET [Status] = 'Closed' WHERE [OrderNo] = @OrderNo`
+	snapshot, err := database.TakeSnapshot(ctx, sandbox, "any", statement, database.SnapshotSpec{}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snapshot.Key, []string{"IssueID"}) {
+		t.Errorf("key = %v, want the primary key", snapshot.Key)
+	}
+
+	conn, err := sandbox.Connect(ctx, "any")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, _, err := conn.Exec(ctx, `CREATE TABLE [dbo].[Notes] ([OrderNo] TEXT, [Note] TEXT)`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.Exec(ctx, `INSERT INTO [dbo].[Notes] VALUES ('SO-50001', 'first'), ('SO-50001', 'second')`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	statement = `UPDATE [dbo].[Notes] SET [Note] = 'changed' WHERE [OrderNo] = @OrderNo`
+	snapshot, err = database.TakeSnapshot(ctx, sandbox, "any", statement, database.SnapshotSpec{}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Key) != 0 {
+		t.Fatalf("key = %v for a table without a primary key", snapshot.Key)
+	}
+	for _, merge := range []bool{false, true} {
+		if _, err := database.RestoreSnapshot(ctx, sandbox, snapshot, merge); err == nil {
+			t.Errorf("restore (merge %v) of an UPDATE snapshot without a key should fail", merge)
+		}
+	}
+
+	rows, err := conn.Query(ctx, `SELECT COUNT(*) FROM [dbo].[Notes]`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows.Values[0][0] != int64(2) {
+		t.Errorf("the table has %v rows after the refused restores, want 2", rows.Values[0][0])
+	}
+}
@@ -1,36 +1,64 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 
-	_ "github.com/denisenkom/go-mssqldb"
 	"github.com/robertgouveia/do-my-job/storage"
 )
 
 func Connect(serverName string) (*sql.DB, error) {
+	return ConnectContext(context.Background(), serverName)
+}
+
+func ConnectContext(ctx context.Context, serverName string) (*sql.DB, error) {
+	db, _, err := open(ctx, serverName)
+	return db, err
+}
+
+// open connects to serverName with the dialect its config names.
+func open(ctx context.Context, serverName string) (*sql.DB, Dialect, error) {
 	s, err := storage.LoadServerConfig(serverName)
 	if err != nil {
-		return nil, fmt.Errorf("server config error: %v", err)
+		return nil, Dialect{}, fmt.Errorf("server config error: %w", err)
+	}
+	if missing := s.Missing(); len(missing) > 0 {
+		return nil, Dialect{}, fmt.Errorf("server config error: missing %s", strings.Join(missing, ", "))
 	}
 
-	connStr := fmt.Sprintf(
-		"server=%s;user id=%s;password=%s;database=%s;",
-		s.Host, s.Username, s.Password, s.Database,
-	)
+	dialect, err := DialectFor(s)
+	if err != nil {
+		return nil, Dialect{}, fmt.Errorf("server config error: %w", err)
+	}
 
-	db, err := sql.Open("sqlserver", connStr)
+	db, err := sql.Open(dialect.Driver, dialect.DSN(s, s.Database))
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v -- conn: %s", err, connStr)
+		return nil, dialect, fmt.Errorf("failed to open database: %w -- server: %s", err, s.Host)
 	}
 
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to open database: %v -- conn: %s", err, connStr)
+		return nil, dialect, fmt.Errorf("failed to open database: %w -- server: %s", err, s.Host)
 	}
 
-	return db, nil
+	return db, dialect, nil
+}
+
+func connectionString(s storage.ServerConfig, database string) string {
+	connStr := fmt.Sprintf(
+		"server=%s;user id=%s;password=%s;",
+		s.Host, s.Username, s.Password,
+	)
+	if s.Port != "" {
+		connStr += fmt.Sprintf("port=%s;", s.Port)
+	}
+	if database != "" {
+		connStr += fmt.Sprintf("database=%s;", database)
+	}
+	return connStr
 }
 
 func Execute(db *sql.DB, stmt string, params ...interface{}) (sql.Result, string, error) {
@@ -41,13 +69,13 @@ func Execute(db *sql.DB, stmt string, params ...interface{}) (sql.Result, string
 
 	preparedStmt, err := db.Prepare(stmt)
 	if err != nil {
-		return nil, paramDebug, fmt.Errorf("failed to prepare statement: %v", err)
+		return nil, paramDebug, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer preparedStmt.Close()
 
 	result, err := preparedStmt.Exec(params...)
 	if err != nil {
-		return nil, paramDebug, fmt.Errorf("failed to execute statement: %v", err)
+		return nil, paramDebug, fmt.Errorf("failed to execute statement: %w", err)
 	}
 
 	return result, paramDebug, nil
@@ -62,51 +90,43 @@ func CreateNamedParameters(values ...interface{}) map[string]interface{} {
 }
 
 func ExecuteWithNamedParams(db *sql.DB, stmt string, params map[string]interface{}) (sql.Result, string, error) {
-	paramDebug := ""
-	for name, value := range params {
-		paramDebug += fmt.Sprintf("[%s : %v] ", name, value)
-	}
+	return ExecuteWithNamedParamsContext(context.Background(), db, stmt, params)
+}
 
-	preparedStmt, err := db.Prepare(stmt)
+// ExecuteWithNamedParamsContext runs a SQL Server statement directly on
+// db. Connections from a Connector use their own dialect instead.
+func ExecuteWithNamedParamsContext(ctx context.Context, db *sql.DB, stmt string, params map[string]interface{}) (sql.Result, string, error) {
+	paramDebug := formatParams(params)
+
+	bound, args, err := MSSQL.Bind(stmt, params)
 	if err != nil {
-		return nil, paramDebug, fmt.Errorf("failed to prepare statement: %v", err)
+		return nil, paramDebug, err
 	}
-	defer preparedStmt.Close()
 
-	var orderedParams []interface{}
-	paramNames := extractParamNames(stmt)
-	for _, name := range paramNames {
-		if value, exists := params[name]; exists {
-			orderedParams = append(orderedParams, sql.Named(name, value))
-		} else {
-			return nil, paramDebug, fmt.Errorf("missing parameter: %s", name)
-		}
+	preparedStmt, err := db.PrepareContext(ctx, bound)
+	if err != nil {
+		return nil, paramDebug, fmt.Errorf("failed to prepare statement: %w", err)
 	}
+	defer preparedStmt.Close()
 
-	result, err := preparedStmt.Exec(orderedParams...)
+	result, err := preparedStmt.ExecContext(ctx, args...)
 	if err != nil {
-		return nil, paramDebug, fmt.Errorf("failed to execute statement: %v", err)
+		return nil, paramDebug, fmt.Errorf("failed to execute statement: %w", err)
 	}
 
 	return result, paramDebug, nil
 }
 
-func extractParamNames(stmt string) []string {
-	var params []string
-	parts := strings.Split(stmt, "@")
-	for i := 1; i < len(parts); i++ {
-		var name string
-		for j := 0; j < len(parts[i]); j++ {
-			if (parts[i][j] >= 'a' && parts[i][j] <= 'z') ||
-				(parts[i][j] >= 'A' && parts[i][j] <= 'Z') ||
-				(parts[i][j] >= '0' && parts[i][j] <= '9') ||
-				parts[i][j] == '_' {
-				name += string(parts[i][j])
-			} else {
-				break
-			}
-		}
-		params = append(params, name)
+func formatParams(params map[string]interface{}) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
 	}
-	return params
+	sort.Strings(names)
+
+	paramDebug := ""
+	for _, name := range names {
+		paramDebug += fmt.Sprintf("[%s : %v] ", name, params[name])
+	}
+	return paramDebug
 }
@@ -0,0 +1,326 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	KindNumber = "number"
+	KindText   = "text"
+	KindDate   = "date"
+	KindBinary = "binary"
+	KindOther  = "other"
+)
+
+// Column is one column as the server describes it.
+type Column struct {
+	Schema   string
+	Table    string
+	Name     string
+	DataType string
+	// MaxLength is the character limit, -1 for max and 0 when not known.
+	MaxLength int64
+	Nullable  bool
+	// Generated is set for columns the server fills itself, such as
+	// rowversion, identity and computed columns, which cannot be written.
+	Generated bool
+}
+
+// Kind groups the many type names the dialects use into the few that
+// matter when deciding whether a value fits.
+func (c Column) Kind() string {
+	t := strings.ToLower(c.DataType)
+	switch {
+	// SQL Server's rowversion is a counter, despite its timestamp alias
+	case t == "rowversion":
+		return KindBinary
+	case strings.Contains(t, "date"), strings.Contains(t, "time"):
+		return KindDate
+	case strings.Contains(t, "int"), strings.Contains(t, "dec"), strings.Contains(t, "numeric"),
+		strings.Contains(t, "float"), strings.Contains(t, "real"), strings.Contains(t, "double"),
+		strings.Contains(t, "money"), t == "bit":
+		return KindNumber
+	case strings.Contains(t, "char"), strings.Contains(t, "text"), strings.Contains(t, "clob"),
+		t == "uniqueidentifier", t == "uuid":
+		return KindText
+	case strings.Contains(t, "binary"), strings.Contains(t, "blob"), t == "image", t == "bytea":
+		return KindBinary
+	}
+	return KindOther
+}
+
+// Type is the data type with its length, e.g. nvarchar(20).
+func (c Column) Type() string {
+	if c.MaxLength > 0 {
+		return fmt.Sprintf("%s(%d)", c.DataType, c.MaxLength)
+	}
+	return c.DataType
+}
+
+// Accepts reports why value cannot be stored in the column without an
+// error or silent truncation, or nil when it fits.
+func (c Column) Accepts(value interface{}) error {
+	switch c.Kind() {
+	case KindNumber:
+		if s, ok := value.(string); ok {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err != nil {
+				return fmt.Errorf("%q is not a number but %s is %s", s, c.Name, c.Type())
+			}
+		}
+	case KindText:
+		if c.MaxLength > 0 {
+			if text := fmt.Sprint(value); int64(utf8.RuneCountInString(text)) > c.MaxLength {
+				return fmt.Errorf("%q is too long for %s %s", text, c.Name, c.Type())
+			}
+		}
+	case KindBinary:
+		return fmt.Errorf("%s is %s", c.Name, c.Type())
+	}
+	return nil
+}
+
+// dateLayouts are the forms Convert accepts for dates. Day and month
+// orders are too easily mixed up to guess at, so only ISO forms are used.
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// Convert turns text read from a file into the value to store in the
+// column. Blank text is NULL, except for text columns that cannot be
+// NULL, where it is the empty string.
+func (c Column) Convert(text string) (interface{}, error) {
+	kind := c.Kind()
+	if text == "" || kind != KindText && strings.TrimSpace(text) == "" {
+		switch {
+		case c.Nullable:
+			return nil, nil
+		case kind != KindText:
+			return nil, fmt.Errorf("%s cannot be empty", c.Name)
+		}
+	}
+
+	trimmed := strings.TrimSpace(text)
+	t := strings.ToLower(c.DataType)
+	switch kind {
+	case KindNumber:
+		if t == "bit" {
+			b, err := strconv.ParseBool(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not true or false but %s is %s", text, c.Name, c.Type())
+			}
+			return b, nil
+		}
+		if strings.Contains(t, "int") {
+			if n, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+				return n, nil
+			}
+			return nil, fmt.Errorf("%q is not a whole number but %s is %s", text, c.Name, c.Type())
+		}
+		f, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number but %s is %s", text, c.Name, c.Type())
+		}
+		return f, nil
+	case KindDate:
+		for _, layout := range dateLayouts {
+			if d, err := time.Parse(layout, trimmed); err == nil {
+				return d, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a YYYY-MM-DD date but %s is %s", text, c.Name, c.Type())
+	case KindText, KindBinary:
+		if err := c.Accepts(text); err != nil {
+			return nil, err
+		}
+	}
+	return text, nil
+}
+
+// Binding is a column the statement assigns or compares to a parameter
+// or a literal.
+type Binding struct {
+	Column  string
+	Param   string
+	Literal interface{}
+}
+
+var binding = regexp.MustCompile(`(?i)((?:(?:\[[^\]]+\]|[A-Za-z_]\w*)\s*\.\s*)*(?:\[[^\]]+\]|[A-Za-z_]\w*))\s*(?:=|<>|!=|<=|>=|<|>|\bLIKE\b)\s*(@\w+|N?'(?:[^']|'')*'|-?\d+(?:\.\d+)?)`)
+
+// StatementBindings finds the column = value pairs in SET and WHERE
+// clauses. Table prefixes are dropped from the column names.
+func StatementBindings(statement string) []Binding {
+	var bindings []Binding
+	for _, match := range binding.FindAllStringSubmatch(statement, -1) {
+		parts := namePart.FindAllString(match[1], -1)
+		b := Binding{Column: unbracket(parts[len(parts)-1])}
+
+		value := match[2]
+		switch {
+		case strings.HasPrefix(value, "@"):
+			b.Param = value[1:]
+		case strings.HasSuffix(value, "'"):
+			value = strings.TrimPrefix(strings.TrimPrefix(value, "N"), "n")
+			b.Literal = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		default:
+			b.Literal = value
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				b.Literal = n
+			} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+				b.Literal = f
+			}
+		}
+
+		bindings = append(bindings, b)
+	}
+	return bindings
+}
+
+// Columns looks up the columns of tables, given as [schema.]table, on
+// serverName. Tables that do not exist are left out.
+func Columns(ctx context.Context, connector Connector, serverName string, tables []string) ([]Column, error) {
+	if len(tables) == 0 {
+		return nil, nil
+	}
+
+	conn, err := connector.Connect(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	dialect := conn.Dialect()
+	if dialect.ColumnsQuery == "" {
+		return nil, fmt.Errorf("cannot list columns on %s", dialect.Name)
+	}
+
+	params := make(map[string]interface{}, len(tables))
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		name := fmt.Sprintf("t%d", i+1)
+		names[i] = "@" + name
+		_, params[name] = splitTable(table)
+	}
+
+	rows, err := conn.Query(ctx, fmt.Sprintf(dialect.ColumnsQuery, strings.Join(names, ", ")), params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list columns: %w", err)
+	}
+
+	columns := make([]Column, 0, len(rows.Values))
+	for _, row := range rows.Values {
+		if len(row) < 6 {
+			return nil, fmt.Errorf("failed to list columns: expected 6 columns, got %d", len(row))
+		}
+		columns = append(columns, Column{
+			Schema:    toString(row[0]),
+			Table:     toString(row[1]),
+			Name:      toString(row[2]),
+			DataType:  toString(row[3]),
+			MaxLength: toInt64(row[4]),
+			Nullable:  strings.EqualFold(toString(row[5]), "YES"),
+			Generated: len(row) > 6 && toInt64(row[6]) == 1,
+		})
+	}
+
+	return columns, nil
+}
+
+// ValidateStatement checks that the tables and columns statement uses
+// exist on serverName and that the values it will be given fit them.
+// values maps each parameter the caller sets to the values it may take;
+// an empty list means any value, e.g. one typed in by the user.
+func ValidateStatement(ctx context.Context, connector Connector, serverName, statement string, values map[string][]interface{}) ([]string, error) {
+	tables := StatementTables(statement)
+
+	columns, err := Columns(ctx, connector, serverName, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+
+	var found []Column
+	for _, table := range tables {
+		matched := tableColumns(columns, table)
+		if len(matched) == 0 {
+			problems = append(problems, fmt.Sprintf("table %s does not exist", table))
+		}
+		found = append(found, matched...)
+	}
+	if len(found) == 0 {
+		return problems, nil
+	}
+
+	for _, b := range StatementBindings(statement) {
+		column, ok := findColumn(found, b.Column)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("column %s does not exist on %s", b.Column, strings.Join(tables, ", ")))
+			continue
+		}
+
+		if b.Param == "" {
+			if err := column.Accepts(b.Literal); err != nil {
+				problems = append(problems, err.Error())
+			}
+			continue
+		}
+
+		candidates, ok := values[b.Param]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("@%s is used for %s but never set", b.Param, column.Name))
+			continue
+		}
+		for _, value := range candidates {
+			if err := column.Accepts(value); err != nil {
+				problems = append(problems, fmt.Sprintf("@%s: %v", b.Param, err))
+				break
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// splitTable separates an optional schema from a table name.
+func splitTable(table string) (string, string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// tableColumns picks out table's columns. The schema has to match when
+// one is given, unless the server has no table by that name in it, as
+// MySQL puts its tables in the database rather than a schema.
+func tableColumns(columns []Column, table string) []Column {
+	schema, name := splitTable(table)
+
+	var byName, bySchema []Column
+	for _, c := range columns {
+		if !strings.EqualFold(c.Table, name) {
+			continue
+		}
+		byName = append(byName, c)
+		if strings.EqualFold(c.Schema, schema) {
+			bySchema = append(bySchema, c)
+		}
+	}
+
+	if schema == "" || len(bySchema) == 0 {
+		return byName
+	}
+	return bySchema
+}
+
+func findColumn(columns []Column, name string) (Column, bool) {
+	for _, c := range columns {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatementBindings(t *testing.T) {
+	got := StatementBindings(`UPDATE h SET h.[Status] = @Status, [Note] = N'it''s done', Qty = 3
+WHERE [Sales Order No_] = @OrderNo AND Price >= 1.5`)
+	want := []Binding{
+		{Column: "Status", Param: "Status"},
+		{Column: "Note", Literal: "it's done"},
+		{Column: "Qty", Literal: int64(3)},
+		{Column: "Sales Order No_", Param: "OrderNo"},
+		{Column: "Price", Literal: 1.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestColumnAccepts(t *testing.T) {
+	status := Column{Name: "Status", DataType: "int"}
+	if status.Accepts(3) != nil || status.Accepts("4") != nil {
+		t.Error("numbers should fit an int column")
+	}
+	if status.Accepts("Logged") == nil {
+		t.Error("text should not fit an int column")
+	}
+
+	service := Column{Name: "Shipping Agent Service", DataType: "nvarchar", MaxLength: 10}
+	if service.Accepts("48") != nil {
+		t.Error("short text should fit")
+	}
+	if service.Accepts("NEXT DAY BEFORE NOON") == nil {
+		t.Error("long text should be rejected")
+	}
+
+	if (Column{DataType: "varbinary"}).Accepts("x") == nil {
+		t.Error("binary columns should be flagged")
+	}
+
+	if kind := (Column{DataType: "rowversion"}).Kind(); kind != KindBinary {
+		t.Errorf("rowversion is a %s column, want binary", kind)
+	}
+}
+
+func TestColumnConvert(t *testing.T) {
+	tests := []struct {
+		column Column
+		text   string
+		want   interface{}
+	}{
+		{Column{DataType: "int"}, " 42 ", int64(42)},
+		{Column{DataType: "decimal"}, "1.5", 1.5},
+		{Column{DataType: "bit"}, "1", true},
+		{Column{DataType: "datetime"}, "2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Column{DataType: "int", Nullable: true}, "", nil},
+		{Column{DataType: "nvarchar"}, "", ""},
+		{Column{DataType: "nvarchar", Nullable: true}, "", nil},
+	}
+	for _, tt := range tests {
+		got, err := tt.column.Convert(tt.text)
+		if err != nil || got != tt.want {
+			t.Errorf("%s Convert(%q) = %#v, %v, want %#v", tt.column.DataType, tt.text, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []struct {
+		column Column
+		text   string
+	}{
+		{Column{DataType: "int"}, "1.5"},
+		{Column{DataType: "int"}, ""},
+		{Column{DataType: "datetime"}, "01/03/2024"},
+		{Column{DataType: "nvarchar", MaxLength: 2}, "abc"},
+	} {
+		if _, err := bad.column.Convert(bad.text); err == nil {
+			t.Errorf("%s Convert(%q) should fail", bad.column.Type(), bad.text)
+		}
+	}
+}
+
+// TestValidateStatementAgainstSandbox reads the columns from SQLite, so
+// the pragma based ColumnsQuery is covered too.
+func TestValidateStatementAgainstSandbox(t *testing.T) {
+	sandbox, err := OpenSandbox(filepath.Join(t.TempDir(), "sandbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	problems, err := ValidateStatement(ctx, sandbox, "any", ShippingChange, map[string][]interface{}{"OrderNo": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("unexpected problems: %q", problems)
+	}
+
+	drifted := `UPDATE [dbo].[Goods Outward Header] SET [Shipping Agent Service Code] = '48' WHERE [Sales Order No_] = @OrderNo AND [Old] = @Old`
+	problems, err = ValidateStatement(ctx, sandbox, "any", drifted, map[string][]interface{}{"OrderNo": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"column Shipping Agent Service Code does not exist on dbo.Goods Outward Header",
+		"column Old does not exist on dbo.Goods Outward Header",
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q", problems)
+	}
+
+	problems, _ = ValidateStatement(ctx, sandbox, "any", `UPDATE [dbo].[Sales Line] SET [Qty] = 1`, nil)
+	if !reflect.DeepEqual(problems, []string{"table dbo.Sales Line does not exist"}) {
+		t.Errorf("problems = %q", problems)
+	}
+}
@@ -0,0 +1,74 @@
+package menu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/robertgouveia/do-my-job/storage"
+	"github.com/robertgouveia/do-my-job/tea"
+)
+
+var environmentColours = map[string]string{
+	storage.EnvDev:  "#2E8B57",
+	storage.EnvTest: "#D7AF00",
+	storage.EnvProd: "#D70000",
+}
+
+func EnvironmentMenu(mainMenu *tea.TeaModel) *tea.TeaModel {
+	environmentMenu := tea.Create("Environment")
+	mainMenu.AddSubmenu("Environment", environmentMenu)
+	mainMenu.Banner = environmentBanner
+
+	for _, env := range storage.Environments {
+		env := env
+
+		environmentMenu.AddMenuItem(strings.ToUpper(env), func() tea.Result {
+			if err := storage.SetCurrentEnvironment(env); err != nil {
+				return tea.Show(fmt.Sprintf("Error switching environment: %v", err))
+			}
+			return tea.Back()
+		})
+	}
+
+	return environmentMenu
+}
+
+func environmentBanner() string {
+	env := storage.CurrentEnvironment()
+	label := fmt.Sprintf("ENVIRONMENT: %s", strings.ToUpper(env))
+
+	if tea.CurrentTheme().Plain {
+		return "[ " + label + " ]"
+	}
+
+	return lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 1).
+		Foreground(lipgloss.Color("#FFFFFF")).
+		Background(lipgloss.Color(environmentColours[env])).
+		Render(label)
+}
+
+func environmentSelectTemplate(serverName string, config *storage.ServerConfig) *tea.TeaModel {
+	environmentMenu := tea.Create("Set Environment")
+
+	for _, env := range storage.Environments {
+		env := env
+
+		environmentMenu.AddMenuItem(strings.ToUpper(env), func() tea.Result {
+			updated, err := storage.UpdateServerConfig(serverName, func(c *storage.ServerConfig) {
+				c.Environment = env
+			})
+			forgetServerConfigs()
+			if err != nil {
+				return tea.Show(fmt.Sprintf("Error saving environment: %v", err))
+			}
+
+			*config = updated
+			return tea.Back()
+		})
+	}
+
+	return environmentMenu
+}
@@ -0,0 +1,101 @@
+package menu
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robertgouveia/do-my-job/storage"
+)
+
+const (
+	historyNamespace = "history"
+	historyLimit     = 50
+	historyKeyFormat = "20060102T150405.000000000"
+)
+
+// historyEntry records one script run, successful or not.
+type historyEntry struct {
+	Time        time.Time `json:"time"`
+	Script      string    `json:"script"`
+	Server      string    `json:"server"`
+	Environment string    `json:"environment"`
+	Operator    string    `json:"operator"`
+	Params      string    `json:"params"`
+	Rows        int64     `json:"rows"`
+	Retries     int       `json:"retries"`
+	Error       string    `json:"error,omitempty"`
+	Sandbox     bool      `json:"sandbox,omitempty"`
+	// Snapshot is the file the rows were backed up to before the run.
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// recordHistory keeps one document per run, keyed by time so List returns
+// them in order. Failing to write history must not fail the run itself.
+func recordHistory(entry historyEntry) {
+	entry.Time = entry.Time.UTC()
+	entry.Operator = currentOperator()
+	entry.Sandbox = sandbox != nil
+
+	key := entry.Time.Format(historyKeyFormat)
+	if err := storage.Default().Namespace(historyNamespace).Save(key, entry); err != nil {
+		log.Printf("Warning: could not record history: %v", err)
+	}
+}
+
+// loadHistory returns up to limit runs, newest first.
+func loadHistory(limit int) ([]historyEntry, error) {
+	records := storage.Default().Namespace(historyNamespace)
+
+	keys, err := records.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	entries := make([]historyEntry, 0, len(keys))
+	for _, key := range keys {
+		var entry historyEntry
+		if err := records.Load(key, &entry); err != nil {
+			return entries, fmt.Errorf("failed to load history entry %s: %w", key, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func formatHistory(entries []historyEntry) string {
+	if len(entries) == 0 {
+		return "No scripts have been run yet.\n"
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		outcome := fmt.Sprintf("%d rows", e.Rows)
+		if e.Error != "" {
+			outcome = "FAILED: " + e.Error
+		}
+		if e.Retries > 0 {
+			outcome += fmt.Sprintf(" after %d retries", e.Retries)
+		}
+
+		target := fmt.Sprintf("%s [%s]", e.Server, strings.ToUpper(e.Environment))
+		if e.Sandbox {
+			target += " (sandbox)"
+		}
+
+		b.WriteString(fmt.Sprintf("%s  %s  %s\n", e.Time.Local().Format("02 Jan 15:04:05"), e.Script, target))
+		b.WriteString(fmt.Sprintf("  by %s  %s  %s\n", e.Operator, strings.TrimSpace(e.Params), outcome))
+		if e.Snapshot != "" {
+			b.WriteString(fmt.Sprintf("  snapshot %s\n", e.Snapshot))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,216 @@
+package menu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/storage"
+	"github.com/robertgouveia/do-my-job/tea"
+)
+
+const (
+	importPreviewRows     = 5
+	importPreviewProblems = 10
+)
+
+// importSource is what the Bulk Import menu loads, as set in its form.
+type importSource struct {
+	Server string
+	Table  string
+	File   string
+}
+
+// prepare reads the file and matches it against the table. The file is
+// read afresh each time so edits made after a preview are picked up.
+func (s importSource) prepare(ctx context.Context) (storage.ServerConfig, database.Import, error) {
+	if s.Server == "" || s.Table == "" || s.File == "" {
+		return storage.ServerConfig{}, database.Import{}, errors.New("set the server, table and CSV file first")
+	}
+
+	config, err := resolveServer(s.Server)
+	if config.IsZero() {
+		return config, database.Import{}, fmt.Errorf("%s is not configured", s.Server)
+	}
+	if err != nil {
+		return config, database.Import{}, err
+	}
+
+	f, err := os.Open(s.File)
+	if err != nil {
+		return config, database.Import{}, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer f.Close()
+
+	imp, err := database.PrepareImport(ctx, database.Default(), s.Server, s.Table, f)
+	if err != nil {
+		return config, imp, fmt.Errorf("failed to prepare import from %s: %w", filepath.Base(s.File), err)
+	}
+	return config, imp, nil
+}
+
+func validateCSVFile(value string) error {
+	info, err := os.Stat(strings.TrimSpace(value))
+	switch {
+	case err != nil:
+		return fmt.Errorf("file not found")
+	case info.IsDir():
+		return fmt.Errorf("is a directory")
+	}
+	return nil
+}
+
+func importTemplate() *tea.TeaModel {
+	importMenu := tea.Create("Bulk Import")
+	source := &importSource{}
+
+	importMenu.Status = func() []tea.StatusSegment {
+		server := tea.StatusSegment{Label: "Server", Value: "not set", Warn: true}
+		switch {
+		case source.Server != "" && sandbox != nil:
+			server = tea.StatusSegment{Label: "Server", Value: source.Server + " (sandbox)"}
+		case source.Server != "":
+			server = serverSegment(source.Server)
+		}
+
+		table := tea.StatusSegment{Label: "Table", Value: source.Table}
+		file := tea.StatusSegment{Label: "File", Value: filepath.Base(source.File)}
+		for _, segment := range []*tea.StatusSegment{&table, &file} {
+			if segment.Value == "" || segment.Value == "." {
+				segment.Value, segment.Warn = "not set", true
+			}
+		}
+		return []tea.StatusSegment{server, table, file}
+	}
+
+	importMenu.AddForm("Set Source", func() []tea.FormField {
+		return []tea.FormField{
+			{Label: "Server", Value: source.Server, Validate: tea.Required},
+			{Label: "Table", Value: source.Table, Placeholder: "e.g. dbo.Shipping Corrections", Validate: tea.Required},
+			{Label: "CSV File", Value: source.File, Placeholder: "path to a CSV file with a header row", Validate: validateCSVFile},
+		}
+	}, func(values []string) tea.Result {
+		source.Server = strings.TrimSpace(values[0])
+		source.Table = strings.TrimSpace(values[1])
+		source.File = strings.TrimSpace(values[2])
+		return tea.None()
+	})
+
+	importMenu.AddTask("Preview", func(ctx context.Context, report func(tea.Progress)) tea.Result {
+		report(tea.Progress{Fraction: -1, Message: fmt.Sprintf("Checking %s against %s...", filepath.Base(source.File), source.Table)})
+		_, imp, err := source.prepare(ctx)
+		if err != nil {
+			return tea.Fail(err, nil)
+		}
+		return tea.Show(formatImport(*source, imp))
+	})
+
+	confirmProd := func() string {
+		config, err := resolveServer(source.Server)
+		if err == nil && config.Env() == storage.EnvProd {
+			return source.Server
+		}
+		return ""
+	}
+
+	importMenu.AddGuardedTask("Import", confirmProd, func(ctx context.Context, report func(tea.Progress)) tea.Result {
+		report(tea.Progress{Fraction: -1, Message: fmt.Sprintf("Checking %s against %s...", filepath.Base(source.File), source.Table)})
+		config, imp, err := source.prepare(ctx)
+		if err != nil {
+			return tea.Fail(err, nil)
+		}
+		if len(imp.Problems) > 0 {
+			return tea.Fail(fmt.Errorf("not imported, %s has %d problems. Preview lists them.", filepath.Base(source.File), len(imp.Problems)), nil)
+		}
+
+		rows, err := database.BulkImport(ctx, database.Default(), source.Server, imp, func(sent, total int) {
+			report(tea.Progress{Fraction: float64(sent) / float64(total), Message: fmt.Sprintf("Loaded %d of %d rows into %s...", sent, total, imp.Table)})
+		})
+
+		entry := historyEntry{
+			Time:        time.Now(),
+			Script:      "Bulk Import into " + imp.Table,
+			Server:      source.Server,
+			Environment: config.Env(),
+			Params:      fmt.Sprintf("[File:%s] [Rows:%d]", filepath.Base(source.File), len(imp.Rows)),
+			Rows:        rows,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		recordHistory(entry)
+
+		if err != nil {
+			return tea.Fail(fmt.Errorf("failed to import into %s on %s: %w", imp.Table, source.Server, err), nil)
+		}
+		return tea.Show(fmt.Sprintf("Imported %d rows from %s into %s on %s.", rows, filepath.Base(source.File), imp.Table, source.Server))
+	})
+
+	return importMenu
+}
+
+func formatImport(source importSource, imp database.Import) string {
+	glyph := tea.CurrentTheme().WarnGlyph
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%d rows from %s into %s on %s\n", len(imp.Rows), filepath.Base(source.File), imp.Table, source.Server))
+
+	width := 0
+	for _, name := range imp.Header {
+		width = max(width, len(name))
+	}
+	b.WriteString("\nColumns\n")
+	for i, name := range imp.Header {
+		target := "(no match)"
+		if c := imp.Columns[i]; c.Name != "" {
+			target = fmt.Sprintf("%s %s", c.Name, c.Type())
+			if c.Nullable {
+				target += " null"
+			}
+		}
+		b.WriteString(fmt.Sprintf("  %-*s -> %s\n", width, name, target))
+	}
+
+	if len(imp.Rows) > 0 {
+		b.WriteString(fmt.Sprintf("\nFirst %d rows\n", min(len(imp.Rows), importPreviewRows)))
+		for _, row := range imp.Rows[:min(len(imp.Rows), importPreviewRows)] {
+			values := make([]string, len(row))
+			for i, value := range row {
+				values[i] = formatImportValue(value)
+			}
+			b.WriteString("  " + strings.Join(values, " | ") + "\n")
+		}
+	}
+
+	if len(imp.Problems) == 0 {
+		b.WriteString("\nEvery value fits. Choose Import to load the rows.\n")
+		return b.String()
+	}
+
+	b.WriteString(fmt.Sprintf("\n%s %d problems, nothing will be imported until they are fixed:\n", glyph, len(imp.Problems)))
+	for _, problem := range imp.Problems[:min(len(imp.Problems), importPreviewProblems)] {
+		b.WriteString("  " + problem + "\n")
+	}
+	if more := len(imp.Problems) - importPreviewProblems; more > 0 {
+		b.WriteString(fmt.Sprintf("  ... and %d more\n", more))
+	}
+	return b.String()
+}
+
+func formatImportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(value)
+}
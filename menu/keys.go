@@ -0,0 +1,32 @@
+package menu
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/robertgouveia/do-my-job/tea"
+)
+
+const keysFile = "keys.json"
+
+// LoadKeyMap reads keys.json from the config dir, e.g.
+//
+//	{"bindings": {"quit": ["ctrl+q"]}, "quit_behaviour": "back", "confirm_quit": false}
+//
+// Unknown actions are logged and the rest of the file still applies.
+func LoadKeyMap() tea.KeyMap {
+	var config tea.KeyConfig
+	if err := loadUserFile(keysFile, &config); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: could not load key bindings: %v", err)
+		}
+		return tea.DefaultKeyMap()
+	}
+
+	keyMap, err := tea.DefaultKeyMap().Apply(config)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return keyMap
+}
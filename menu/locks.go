@@ -0,0 +1,125 @@
+package menu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/storage"
+)
+
+const scriptsNamespace = "scripts"
+
+// scriptSettings are the per-script choices made in the menus, keyed by
+// script title.
+type scriptSettings struct {
+	LockTimeout string `json:"lock_timeout,omitempty"`
+}
+
+func loadScriptSettings(title string) scriptSettings {
+	var settings scriptSettings
+	err := storage.Default().Namespace(scriptsNamespace).Load(title, &settings)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Printf("Warning: could not load settings for %s: %v", title, err)
+	}
+	return settings
+}
+
+func saveLockTimeout(title, timeout string) error {
+	var settings scriptSettings
+	err := storage.Default().Namespace(scriptsNamespace).Update(title, &settings, func() error {
+		settings.LockTimeout = timeout
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save lock timeout: %w", err)
+	}
+	return nil
+}
+
+// validateLockTimeout accepts a Go duration, or blank to wait for locks
+// indefinitely.
+func validateLockTimeout(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("use a duration like 10s or 500ms")
+	}
+	if d < time.Millisecond {
+		return fmt.Errorf("must be at least 1ms")
+	}
+	return nil
+}
+
+func formatLockTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "none"
+	}
+	return d.String()
+}
+
+// checkLocks is the pre-flight for write scripts. Sessions the statement
+// would wait for come back as a warning, as the run stops waiting after
+// timeout anyway; only without a timeout do they stop it. A note also
+// says when the check itself could not be made.
+func checkLocks(ctx context.Context, serverName, statement string, timeout time.Duration) (string, error) {
+	report, err := database.CheckLocks(ctx, database.Default(), serverName, database.StatementTables(statement))
+	switch {
+	case errors.Is(err, database.ErrLockCheckUnsupported):
+		return "", nil
+	case err != nil:
+		return fmt.Sprintf("Lock check skipped: %v", err), nil
+	}
+
+	conflicts := report.Conflicts()
+	if len(conflicts) == 0 {
+		return "", nil
+	}
+
+	report.Holders = conflicts
+	if timeout <= 0 {
+		return "", fmt.Errorf("not run, %s is busy and the script has no lock timeout:\n%s\nSet a lock timeout to run it anyway, or press r to check again once they finish.", serverName, formatLocks(report))
+	}
+	return fmt.Sprintf("Warning: %s was busy, locks were waited on for at most %s.\n%s", serverName, formatLockTimeout(timeout), formatLocks(report)), nil
+}
+
+func formatLocks(report database.LockReport) string {
+	tables := strings.Join(report.Tables, ", ")
+	if len(report.Holders) == 0 {
+		return fmt.Sprintf("No other sessions hold locks on %s.\n", tables)
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Sessions holding locks on %s:\n", tables))
+	for _, h := range report.Holders {
+		b.WriteString(fmt.Sprintf("\n  Session %d  %s on %s", h.SessionID, h.Login, h.Host))
+		if h.Program != "" {
+			b.WriteString(fmt.Sprintf(" (%s)", h.Program))
+		}
+		b.WriteString("\n")
+
+		state := "holds"
+		if h.Waiting {
+			state = "waiting for"
+		}
+		b.WriteString(fmt.Sprintf("    %s %s on %s\n", state, strings.Join(h.Modes, ", "), strings.Join(h.Tables, ", ")))
+
+		if !h.TransactionStarted.IsZero() {
+			b.WriteString(fmt.Sprintf("    in a transaction since %s\n", h.TransactionStarted.Format("02 Jan 15:04:05")))
+		}
+		if h.Blocking > 0 {
+			b.WriteString(fmt.Sprintf("    blocking %d other sessions\n", h.Blocking))
+		}
+		if h.BlockedBy > 0 {
+			b.WriteString(fmt.Sprintf("    blocked by session %d (%s for %s)\n", h.BlockedBy, h.WaitType, h.Wait))
+		}
+	}
+
+	return b.String()
+}
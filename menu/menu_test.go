@@ -0,0 +1,747 @@
+package menu
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/database/dbtest"
+	"github.com/robertgouveia/do-my-job/storage"
+	"github.com/robertgouveia/do-my-job/tea"
+	"github.com/robertgouveia/do-my-job/tea/teatest"
+)
+
+func TestMain(m *testing.M) {
+	tea.SetTheme(tea.PlainTheme)
+	os.Exit(m.Run())
+}
+
+type fakeDiagnostics struct {
+	steps []database.DiagnosticStep
+	calls int
+}
+
+func (f *fakeDiagnostics) diagnose(ctx context.Context, serverName string) []database.DiagnosticStep {
+	f.calls++
+	return f.steps
+}
+
+// setup gives each test empty in-memory storage, a fake database and fake
+// connection diagnostics.
+func setup(t *testing.T) (*dbtest.Fake, *fakeDiagnostics) {
+	t.Helper()
+
+	storage.SetDefault(storage.NewMemoryStorage())
+	// snapshots are written under the config dir
+	t.Setenv("HOME", t.TempDir())
+
+	fake := dbtest.New()
+	oldConnector := database.Default()
+	database.SetDefault(fake)
+
+	diagnostics := &fakeDiagnostics{}
+	oldDiagnose := diagnose
+	diagnose = diagnostics.diagnose
+
+	connectionsMu.Lock()
+	connections = make(map[string]connectionState)
+	connectionsMu.Unlock()
+
+	permissionsMu.Lock()
+	permissions = make(map[string]permissionState)
+	permissionsMu.Unlock()
+	forgetServerConfigs()
+
+	t.Cleanup(func() {
+		database.SetDefault(oldConnector)
+		diagnose = oldDiagnose
+	})
+	return fake, diagnostics
+}
+
+// expectSnapshot answers the queries Execute makes to back up the
+// shipping script's rows.
+func expectSnapshot(fake *dbtest.Fake) {
+	fake.OnQuery("INFORMATION_SCHEMA.COLUMNS", database.Rows{Values: [][]interface{}{
+		{"dbo", "Goods Outward Header", "Sales Order No_", "nvarchar", int64(20), "NO"},
+		{"dbo", "Goods Outward Header", "Shipping Agent Service", "nvarchar", int64(10), "NO"},
+	}}, nil).OnQuery("INFORMATION_SCHEMA.TABLE_CONSTRAINTS", database.Rows{Values: [][]interface{}{
+		{"Sales Order No_"},
+	}}, nil).OnQuery("FROM [dbo].[Goods Outward Header]", database.Rows{Values: [][]interface{}{{"SO-1001", "NEXT DAY"}}}, nil)
+}
+
+func saveServer(t *testing.T, name, env string) {
+	t.Helper()
+
+	err := storage.SaveServerConfig(name, storage.ServerConfig{
+		Host:        "db.example.local",
+		Username:    "svc_support",
+		Password:    "hunter2",
+		Database:    "NAV",
+		Environment: env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mainMenu() *tea.TeaModel {
+	root := tea.Create("Server Configuration Tool")
+	ScriptMenu(root)
+	EnvironmentMenu(root)
+	ServerMenu(root)
+	return root
+}
+
+func TestScriptMenuWarnsAboutUnconfiguredServers(t *testing.T) {
+	setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+
+	d := teatest.New(t, mainMenu()).Press("enter")
+	d.RequireGolden("scripts_unconfigured")
+}
+
+func TestExecuteScriptOnProd(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+	expectSnapshot(fake)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter")
+	d.RequireGolden("script_menu")
+
+	d.Press("enter").Type("SO-1001").Press("ctrl+s")
+	d.Press("down", "down", "down", "down", "enter")
+	if _, ok := d.Model.(*tea.ConfirmModel); !ok {
+		t.Fatalf("expected a prod confirmation, got %T", d.Model)
+	}
+
+	d.Type("RKW Level 1").Press("enter").WaitFor("Rows Affected")
+	d.RequireGolden("script_executed")
+
+	execs := fake.Execs()
+	if len(execs) != 1 {
+		t.Fatalf("expected one statement, got %d", len(execs))
+	}
+	call := execs[0]
+	if call.Server != "RKW Level 1" || call.Statement != database.ShippingChange {
+		t.Errorf("ran %q on %s", call.Statement, call.Server)
+	}
+	if call.Params["OrderNo"] != "SO-1001" {
+		t.Errorf("OrderNo = %v", call.Params["OrderNo"])
+	}
+}
+
+func TestExecuteScriptOnDevSkipsConfirmation(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvDev)
+	expectSnapshot(fake)
+	if err := storage.SetCurrentEnvironment(storage.EnvDev); err != nil {
+		t.Fatal(err)
+	}
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter", "down", "down", "down", "down", "enter").WaitFor("Rows Affected")
+
+	if len(fake.Execs()) != 1 {
+		t.Fatalf("expected one statement, got %d", len(fake.Execs()))
+	}
+}
+
+func TestExecuteScriptEnvironmentMismatch(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+	if err := storage.SetCurrentEnvironment(storage.EnvTest); err != nil {
+		t.Fatal(err)
+	}
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter", "down", "down", "down", "down", "enter").WaitFor("current environment is TEST")
+
+	if len(fake.Calls()) != 0 {
+		t.Errorf("statement ran despite the environment mismatch")
+	}
+}
+
+func TestExecuteScriptFailureCanRetry(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvDev)
+	expectSnapshot(fake)
+	storage.SetCurrentEnvironment(storage.EnvDev)
+	fake.OnExec("UPDATE", 0, errors.New("deadlock victim")).OnExec("UPDATE", 3, nil)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter", "down", "down", "down", "down", "enter").WaitFor("deadlock victim")
+
+	d.Press("r").WaitFor("Rows Affected: 3")
+	if len(fake.Execs()) != 2 {
+		t.Errorf("expected the retry to run the statement again, got %d calls", len(fake.Execs()))
+	}
+}
+
+func TestExecuteScriptRetryOnProdIsConfirmedAgain(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+	expectSnapshot(fake)
+	fake.OnExec("UPDATE", 0, errors.New("deadlock victim")).OnExec("UPDATE", 3, nil)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter", "down", "down", "down", "down", "enter")
+	d.Type("RKW Level 1").Press("enter").WaitFor("deadlock victim")
+
+	d.Press("r")
+	if _, ok := d.Model.(*tea.ConfirmModel); !ok {
+		t.Fatalf("expected the retry to ask for the prod confirmation again, got %T", d.Model)
+	}
+	if len(fake.Execs()) != 1 {
+		t.Fatalf("the retry ran before it was confirmed, got %d calls", len(fake.Execs()))
+	}
+
+	d.Type("RKW Level 1").Press("enter").WaitFor("Rows Affected: 3")
+	if len(fake.Execs()) != 2 {
+		t.Errorf("expected the confirmed retry to run the statement again, got %d calls", len(fake.Execs()))
+	}
+}
+
+func TestTransientFailuresRetryAutomatically(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvDev)
+	expectSnapshot(fake)
+	storage.SetCurrentEnvironment(storage.EnvDev)
+	fake.OnExec("UPDATE", 0, mssql.Error{Number: 1205, Message: "deadlock victim"}).OnExec("UPDATE", 2, nil)
+
+	oldPolicy := database.DefaultRetryPolicy
+	database.DefaultRetryPolicy = database.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	t.Cleanup(func() { database.DefaultRetryPolicy = oldPolicy })
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter", "down", "down", "down", "down", "enter").WaitFor("Retries: 1")
+	if len(fake.Execs()) != 2 {
+		t.Errorf("expected two attempts, got %d", len(fake.Execs()))
+	}
+
+	d = teatest.New(t, mainMenu())
+	d.Press("enter", "down", "down", "enter").WaitFor("Shipping Agent Service Change")
+	if view := d.View(); !strings.Contains(view, "2 rows after 1 retries") {
+		t.Errorf("history does not show the retried run:\n%s", view)
+	}
+}
+
+func TestShowPlanSummarisesTheEstimate(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+
+	showplan, err := os.ReadFile("../database/testdata/showplan_shipping.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.OnPlan("Goods Outward Header", string(showplan), nil)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter")
+	d.Press("enter").Type("SO-1001").Press("ctrl+s")
+	d.Press("down", "down", "enter").WaitFor("estimated rows")
+	d.RequireGolden("script_plan")
+
+	if len(fake.Execs()) != 0 {
+		t.Errorf("showing the plan ran the statement")
+	}
+	var plans []dbtest.Call
+	for _, call := range fake.Calls() {
+		if call.Plan {
+			plans = append(plans, call)
+		}
+	}
+	if len(plans) != 1 || plans[0].Params["OrderNo"] != "SO-1001" {
+		t.Errorf("unexpected plan requests: %+v", plans)
+	}
+}
+
+func TestExecuteWarnsWhenTablesAreLocked(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvDev)
+	expectSnapshot(fake)
+	storage.SetCurrentEnvironment(storage.EnvDev)
+	fake.OnQuery("dm_tran_locks", database.Rows{Values: [][]interface{}{
+		{int64(87), "dbo.Goods Outward Header", "X", "GRANT", "NAVSVC", "WH-APP01", "Job Queue", nil, int64(0), "", int64(0), int64(0), "OBJECT"},
+		{int64(95), "dbo.Goods Outward Header", "X", "GRANT", "NAVSVC", "WH-APP02", "Client", nil, int64(0), "", int64(0), int64(0), "KEY"},
+	}}, nil)
+
+	// the run goes ahead under the script's lock timeout
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter", "down", "down", "down", "down", "enter").WaitFor("Rows Affected")
+	if !strings.Contains(d.View(), "was busy") || !strings.Contains(d.View(), "Session 87  NAVSVC on WH-APP01 (Job Queue)") {
+		t.Errorf("blocking session not shown:\n%s", d.View())
+	}
+	if strings.Contains(d.View(), "Session 95") {
+		t.Errorf("row locks on other rows should not be reported:\n%s", d.View())
+	}
+	execs := fake.Execs()
+	if len(execs) != 1 || execs[0].LockTimeout != 10*time.Second {
+		t.Errorf("unexpected execs: %+v", execs)
+	}
+
+	// without a lock timeout the run would hang behind the table lock
+	if _, err := checkLocks(context.Background(), "RKW Level 1", `UPDATE [dbo].[Goods Outward Header] SET [Status] = 1`, 0); err == nil || !strings.Contains(err.Error(), "no lock timeout") {
+		t.Errorf("expected the run to be refused without a lock timeout, got %v", err)
+	}
+}
+
+func TestSetLockTimeout(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvDev)
+	expectSnapshot(fake)
+	storage.SetCurrentEnvironment(storage.EnvDev)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter", "down", "enter")
+	d.Press("ctrl+u").Type("ages").Press("ctrl+s")
+	if !strings.Contains(d.View(), "use a duration like 10s") {
+		t.Fatalf("invalid timeout accepted:\n%s", d.View())
+	}
+	d.Press("ctrl+u").Type("3s").Press("ctrl+s")
+	if !strings.Contains(d.View(), "Lock timeout: 3s") {
+		t.Errorf("status bar does not show the new timeout:\n%s", d.View())
+	}
+
+	d.Press("down", "down", "down", "enter").WaitFor("Rows Affected")
+	if execs := fake.Execs(); len(execs) != 1 || execs[0].LockTimeout != 3*time.Second {
+		t.Errorf("unexpected execs: %+v", execs)
+	}
+}
+
+func TestValidateScriptsReportsDrift(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+	saveServer(t, "RKW Data Warehouse", storage.EnvProd)
+
+	// after an upgrade Status became a NAV option field, stored as an int
+	fake.OnQuery("INFORMATION_SCHEMA.COLUMNS", database.Rows{Values: [][]interface{}{
+		{"dbo", "DeliveryIssuesHead", "IssueID", "int", nil, "NO"},
+		{"dbo", "DeliveryIssuesHead", "Status", "int", nil, "NO"},
+		{"dbo", "Goods Outward Header", "Sales Order No_", "nvarchar", int64(20), "NO"},
+		{"dbo", "Goods Outward Header", "Shipping Agent Service", "nvarchar", int64(10), "NO"},
+	}}, nil)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "down", "down", "enter").WaitFor("scripts checked")
+	d.RequireGolden("scripts_validated")
+
+	if code := ValidateCommand(io.Discard); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+}
+
+func TestScriptsWithoutPermissionAreDisabled(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+	fake.OnQuery("HAS_PERMS_BY_NAME", database.Rows{Values: [][]interface{}{{int64(0), int64(1)}}}, nil)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter").WaitFor("(disabled)")
+	d.RequireGolden("scripts_disabled")
+
+	d.Press("down", "enter")
+	if !strings.Contains(d.View(), "svc_support lacks UPDATE on dbo.Goods Outward Header") {
+		t.Errorf("expected the reason instead of the script menu:\n%s", d.View())
+	}
+
+	query := fake.Calls()[0]
+	if query.Params["t1"] != "[dbo].[Goods Outward Header]" || query.Params["p1"] != "UPDATE" || query.Params["p2"] != "SELECT" {
+		t.Errorf("unexpected permission query params: %v", query.Params)
+	}
+}
+
+// correctionColumns is how the fake describes the staging table the bulk
+// import tests load into.
+var correctionColumns = database.Rows{Values: [][]interface{}{
+	{"dbo", "Shipping Corrections", "OrderNo", "nvarchar", int64(20), "NO"},
+	{"dbo", "Shipping Corrections", "Service", "nvarchar", int64(10), "NO"},
+	{"dbo", "Shipping Corrections", "Qty", "int", nil, "YES"},
+}}
+
+func writeCSV(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "corrections.csv")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBulkImport(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+	fake.OnQuery("INFORMATION_SCHEMA.COLUMNS", correctionColumns, nil)
+	path := writeCSV(t, "OrderNo,Service,Qty\nSO-1001,NEXT DAY,2\nSO-1002,ECONOMY,\n")
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "down", "down", "down", "enter")
+	d.Press("enter").Type("RKW Level 1").Press("tab").Type("dbo.Shipping Corrections").Press("tab").Type(path).Press("ctrl+s")
+	d.Press("down", "enter").WaitFor("Every value fits")
+	d.RequireGolden("import_preview")
+
+	d.Press("esc", "down", "enter").Type("RKW Level 1").Press("enter").WaitFor("Imported 2 rows")
+
+	var loads []dbtest.Call
+	for _, call := range fake.Execs() {
+		if call.Rows != nil {
+			loads = append(loads, call)
+		}
+	}
+	if len(loads) != 1 || loads[0].Statement != "INSERT BULK dbo.Shipping Corrections" {
+		t.Fatalf("unexpected bulk loads: %+v", loads)
+	}
+	if got := loads[0].Rows[1]; got[0] != "SO-1002" || got[2] != nil {
+		t.Errorf("second row = %v, want SO-1002 with a NULL Qty", got)
+	}
+
+	entries, err := loadHistory(historyLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Script != "Bulk Import into dbo.Shipping Corrections" || entries[0].Rows != 2 {
+		t.Errorf("unexpected history: %+v", entries)
+	}
+}
+
+func TestBulkImportRefusesRowsThatDoNotFit(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+	fake.OnQuery("INFORMATION_SCHEMA.COLUMNS", correctionColumns, nil)
+	path := writeCSV(t, "OrderNo,Service,Qty\nSO-1001,NEXT DAY BEFORE NOON,two\n")
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "down", "down", "down", "enter")
+	d.Press("enter").Type("RKW Level 1").Press("tab").Type("dbo.Shipping Corrections").Press("tab").Type(path).Press("ctrl+s")
+	d.Press("down", "down", "enter").Type("RKW Level 1").Press("enter").WaitFor("has 2 problems")
+
+	if len(fake.Execs()) != 0 {
+		t.Errorf("rows were loaded despite the problems: %+v", fake.Execs())
+	}
+}
+
+func TestEditServerConfiguration(t *testing.T) {
+	setup(t)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("down", "down", "enter", "enter", "enter")
+	d.Type("sql01").Press("tab", "tab").Type("sa").Press("tab").Type("pw").Press("tab").Type("NAV").Press("ctrl+s")
+	d.WaitFor("saved")
+
+	config, err := storage.LoadServerConfig("RKW Data Warehouse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "sql01" || config.Username != "sa" || config.Password != "pw" || config.Database != "NAV" {
+		t.Errorf("saved %+v", config)
+	}
+
+	// the status bar saw the server unconfigured before the edit
+	if segment := serverSegment("RKW Data Warehouse"); segment.Warn {
+		t.Errorf("status bar still shows the config from before the edit: %+v", segment)
+	}
+	if err := storage.DeleteServerConfig("RKW Data Warehouse"); err != nil {
+		t.Fatal(err)
+	}
+	if segment := serverSegment("RKW Data Warehouse"); segment.Warn {
+		t.Errorf("status bar re-read the config instead of using the cache: %+v", segment)
+	}
+}
+
+func TestEditServerConfigurationRefusesStaleForm(t *testing.T) {
+	setup(t)
+	saveServer(t, "RKW Data Warehouse", storage.EnvTest)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("down", "down", "enter", "enter", "enter")
+
+	// another instance saves while the form is open
+	_, err := storage.UpdateServerConfig("RKW Data Warehouse", func(c *storage.ServerConfig) { c.Password = "rotated" })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Press("ctrl+u").Type("sql02").Press("ctrl+s")
+	if _, ok := d.Model.(*tea.ErrorModel); !ok {
+		t.Fatalf("expected the stale save to be refused, got %T", d.Model)
+	}
+	if !strings.Contains(d.View(), "changed elsewhere") {
+		t.Errorf("the error does not say why:\n%s", d.View())
+	}
+
+	config, err := storage.LoadServerConfig("RKW Data Warehouse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host == "sql02" || config.Password != "rotated" {
+		t.Errorf("the stale form overwrote the other change: %+v", config)
+	}
+}
+
+func TestViewConfigurationMasksPassword(t *testing.T) {
+	setup(t)
+	saveServer(t, "RKW Data Warehouse", storage.EnvTest)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("down", "down", "enter", "enter", "down", "down", "down", "enter")
+	d.RequireGolden("server_view_config")
+}
+
+func TestTestConnectionRecordsState(t *testing.T) {
+	_, diagnostics := setup(t)
+	saveServer(t, "RKW Data Warehouse", storage.EnvProd)
+	diagnostics.steps = []database.DiagnosticStep{
+		{Name: "Configuration", OK: true, Detail: "db.example.local"},
+		{Name: "DNS", OK: false, Detail: "no such host", Hint: "Check the host name", Duration: 3 * time.Millisecond},
+	}
+
+	d := teatest.New(t, mainMenu())
+	d.Press("down", "down", "enter", "enter", "down", "down", "enter").WaitFor("Connection Diagnostics")
+	d.RequireGolden("server_test_connection")
+
+	if segment := connectionSegment("RKW Data Warehouse"); !segment.Warn {
+		t.Errorf("failed diagnostics should mark the connection as failed, got %+v", segment)
+	}
+}
+
+func TestStatusCommandReportsDownServers(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+	saveServer(t, "RKW Data Warehouse", storage.EnvProd)
+	fake.OnQuery("SERVERPROPERTY", database.Rows{Columns: []string{""}, Values: [][]interface{}{{"16.0 Developer"}}}, nil)
+	fake.FailConnect("RKW Level 1", errors.New("login failed"))
+
+	var out strings.Builder
+	if code := StatusCommand(&out); code != 1 {
+		t.Errorf("exit code = %d, want 1 when a server is down", code)
+	}
+
+	for _, want := range []string{"RKW Data Warehouse     UP", "16.0 Developer", "RKW Level 1            DOWN", "login failed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestStatusCommandFindsLegacyServers(t *testing.T) {
+	fake, _ := setup(t)
+	fake.OnQuery("SERVERPROPERTY", database.Rows{Columns: []string{""}, Values: [][]interface{}{{"16.0 Developer"}}}, nil)
+
+	var out strings.Builder
+	if code := StatusCommand(&out); code != 1 || !strings.Contains(out.String(), "No servers configured") {
+		t.Errorf("exit code = %d, want 1 with no servers:\n%s", code, out.String())
+	}
+
+	// saved in the root of the config dir, as before namespaces
+	if err := storage.Default().Save("RKW Level 1", storage.ServerConfig{Host: "db.example.local", Username: "sa", Database: "NAV"}); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if code := StatusCommand(&out); code != 0 || !strings.Contains(out.String(), "RKW Level 1            UP") {
+		t.Errorf("exit code = %d, want the legacy server checked:\n%s", code, out.String())
+	}
+}
+
+func TestExecuteTakesSnapshotThatCanBeRestored(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvDev)
+	storage.SetCurrentEnvironment(storage.EnvDev)
+	expectSnapshot(fake)
+
+	d := teatest.New(t, mainMenu())
+	d.Press("enter", "down", "enter")
+	d.Press("enter").Type("SO-1001").Press("ctrl+s")
+	d.Press("down", "down", "down", "down", "enter").WaitFor("Backed up 1 rows first")
+
+	snapshots, err := listSnapshots(snapshotLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Err != nil {
+		t.Fatalf("expected one snapshot, got %+v", snapshots)
+	}
+	snapshot := snapshots[0].Snapshot
+	if snapshot.Where != "[Sales Order No_] = @OrderNo" || snapshot.Params["OrderNo"] != "SO-1001" || len(snapshot.Rows) != 1 ||
+		strings.Join(snapshot.Key, ", ") != "Sales Order No_" {
+		t.Errorf("unexpected snapshot: %+v", snapshot)
+	}
+
+	entries, err := loadHistory(historyLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Snapshot != snapshots[0].Name {
+		t.Errorf("history does not link the snapshot: %+v", entries)
+	}
+
+	var out strings.Builder
+	// the shipping script UPDATEs, so its rows are merged back by default
+	if code := RestoreCommand(&out, []string{snapshots[0].Name}); code != 0 {
+		t.Fatalf("exit code = %d:\n%s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "Restored 1 rows into dbo.Goods Outward Header on RKW Level 1") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	var restores []dbtest.Call
+	for _, call := range fake.Execs() {
+		if strings.HasPrefix(call.Statement, "RESTORE") {
+			restores = append(restores, call)
+		}
+	}
+	if len(restores) != 1 || restores[0].Params["merge"] != true || restores[0].Rows[0][1] != "NEXT DAY" {
+		t.Errorf("unexpected restores: %+v", restores)
+	}
+}
+
+func TestRestoreToProdNeedsConfirmation(t *testing.T) {
+	fake, _ := setup(t)
+	saveServer(t, "RKW Level 1", storage.EnvProd)
+
+	snapshot := database.Snapshot{
+		Server:  "RKW Level 1",
+		Table:   "dbo.Goods Outward Header",
+		Verb:    "DELETE",
+		Key:     []string{"Sales Order No_"},
+		Taken:   time.Now().UTC(),
+		Columns: []database.Column{{Name: "Sales Order No_", DataType: "nvarchar"}},
+		Rows:    [][]interface{}{{"SO-1001"}},
+	}
+	path, err := snapshot.Save(snapshotDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Base(path)
+
+	restores := func() int {
+		n := 0
+		for _, call := range fake.Execs() {
+			if strings.HasPrefix(call.Statement, "RESTORE") {
+				n++
+			}
+		}
+		return n
+	}
+
+	for _, args := range [][]string{{name}, {name, "--confirm", "RKW Level 3 VIC"}} {
+		var out strings.Builder
+		if code := RestoreCommand(&out, args); code != 1 || !strings.Contains(out.String(), `--confirm "RKW Level 1"`) {
+			t.Errorf("%v: exit code = %d:\n%s", args, code, out.String())
+		}
+	}
+	if n := restores(); n != 0 {
+		t.Fatalf("restored %d times without confirmation", n)
+	}
+
+	var out strings.Builder
+	if code := RestoreCommand(&out, []string{name, "--confirm", "RKW Level 1"}); code != 0 {
+		t.Fatalf("exit code = %d:\n%s", code, out.String())
+	}
+	if n := restores(); n != 1 {
+		t.Errorf("restored %d times after confirmation, want 1", n)
+	}
+}
+
+func TestSandboxTestConnectionChecksTheSandbox(t *testing.T) {
+	_, diagnostics := setup(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { sandbox = nil })
+	saveServer(t, "RKW Data Warehouse", storage.EnvProd)
+
+	root := mainMenu()
+	if _, err := SandboxMenu(root); err != nil {
+		t.Fatal(err)
+	}
+
+	d := teatest.New(t, root)
+	d.Press("down", "down", "enter", "enter", "down", "down", "enter").WaitFor("Connection Diagnostics")
+	if diagnostics.calls != 0 {
+		t.Error("Test Connection dialled the real server in sandbox mode")
+	}
+	if !strings.Contains(d.View(), "[PASS] Sandbox") || !strings.Contains(d.View(), "SQLite") {
+		t.Errorf("expected the sandbox to be checked:\n%s", d.View())
+	}
+}
+
+func TestSandboxRunsScriptsWithoutServers(t *testing.T) {
+	setup(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { sandbox = nil })
+
+	root := mainMenu()
+	if _, err := SandboxMenu(root); err != nil {
+		t.Fatal(err)
+	}
+
+	d := teatest.New(t, root)
+	d.Press("enter", "enter")
+	d.RequireGolden("sandbox_script_menu")
+
+	// Dispute Status Change: set the ID, pick "Cancelled", then run it
+	d.Press("enter").Type("1002").Press("ctrl+s")
+	d.Press("down", "enter")
+	for i := 0; i < 8; i++ {
+		d.Press("down")
+	}
+	d.Press("enter", "down", "down", "down", "down", "enter").Type("RKW Data Warehouse").Press("enter").WaitFor("Rows Affected: 1")
+
+	d.Press("esc", "esc", "esc")
+	if d.Model != root {
+		t.Fatalf("expected to be back on the main menu, got %T", d.Model)
+	}
+
+	d.Press("down", "down", "down", "down", "down", "down", "enter", "enter").WaitFor("DeliveryIssuesHead")
+	if !strings.Contains(d.View(), "SO-50002  Cancelled") {
+		t.Errorf("sandbox data does not show the change:\n%s", d.View())
+	}
+}
+
+func TestUserFilesAreReadAsWritten(t *testing.T) {
+	setup(t)
+	t.Setenv("NO_COLOR", "")
+	os.Unsetenv("NO_COLOR")
+
+	dir := storage.GetConfigDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		keysFile:  `{"bindings": {"quit": ["ctrl+q"]}}`,
+		themeFile: `{"base": "plain"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if keys := LoadKeyMap().Quit.Keys(); len(keys) != 1 || keys[0] != "ctrl+q" {
+		t.Errorf("quit keys = %v, want those in keys.json", keys)
+	}
+	if !LoadTheme().Plain {
+		t.Error("the plain base in theme.json was not applied")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(files) {
+		t.Errorf("loading wrote to the config dir: %v", entries)
+	}
+	for name, content := range files {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != content {
+			t.Errorf("%s was rewritten: %s", name, data)
+		}
+	}
+}
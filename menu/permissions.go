@@ -0,0 +1,113 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	bubble "github.com/charmbracelet/bubbletea"
+	"github.com/robertgouveia/do-my-job/database"
+)
+
+const permissionTimeout = 10 * time.Second
+
+// permissionState is the last permission check for a script, against the
+// server it targeted at the time.
+type permissionState struct {
+	server  string
+	missing string
+}
+
+var (
+	permissionsMu sync.Mutex
+	permissions   = make(map[string]permissionState)
+)
+
+type permissionsCheckedMsg struct{}
+
+type permissionJob struct {
+	script string
+	server string
+	login  string
+	needed []database.Permission
+}
+
+// checkPermissions runs when the Scripts menu opens, and refreshes the
+// cached server configs its warnings use. Targets are resolved straight
+// away and only the queries run in the background; scripts whose
+// servers are unset or unreachable are left enabled, as their menu
+// warnings already cover that.
+func checkPermissions() bubble.Cmd {
+	forgetServerConfigs()
+	if sandbox != nil {
+		return nil
+	}
+
+	var jobs []permissionJob
+	for _, script := range scripts() {
+		sname, config, err := script.targetServer()
+		if err != nil || len(config.Missing()) > 0 {
+			continue
+		}
+		jobs = append(jobs, permissionJob{
+			script: script.Title,
+			server: sname,
+			login:  config.Username,
+			needed: database.StatementPermissions(script.Statement),
+		})
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	connector := database.Default()
+	return func() bubble.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), permissionTimeout)
+		defer cancel()
+
+		for _, job := range jobs {
+			missing, err := database.MissingPermissions(ctx, connector, job.server, job.needed)
+
+			permissionsMu.Lock()
+			switch {
+			case err != nil:
+				delete(permissions, job.script)
+			case len(missing) > 0:
+				permissions[job.script] = permissionState{server: job.server, missing: formatMissing(job.login, missing)}
+			default:
+				permissions[job.script] = permissionState{server: job.server}
+			}
+			permissionsMu.Unlock()
+		}
+
+		return permissionsCheckedMsg{}
+	}
+}
+
+func formatMissing(login string, missing []database.Permission) string {
+	names := make([]string, len(missing))
+	for i, p := range missing {
+		names[i] = p.String()
+	}
+	return fmt.Sprintf("%s lacks %s", login, strings.Join(names, ", "))
+}
+
+// permissionReason explains why the script cannot run, if its last check
+// against its current target found permissions missing.
+func (s Script) permissionReason() string {
+	sname, _, err := s.cachedTarget()
+	if err != nil || sandbox != nil {
+		return ""
+	}
+
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+
+	state, ok := permissions[s.Title]
+	if !ok || state.server != sname {
+		return ""
+	}
+	return state.missing
+}
@@ -0,0 +1,71 @@
+package menu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/tea"
+)
+
+// formatPlans summarises estimated plans: the rows each statement expects to
+// touch, any full scans, then warnings and the operator tree.
+func formatPlans(plans []database.Plan) string {
+	glyph := tea.CurrentTheme().WarnGlyph
+
+	var b strings.Builder
+	for i, plan := range plans {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		b.WriteString(fmt.Sprintf("%s  estimated rows: %s  cost: %.2f\n",
+			plan.Type, formatEstimate(plan.EstimatedRows), plan.EstimatedCost))
+
+		if scans := plan.Scans(); len(scans) > 0 {
+			b.WriteString(fmt.Sprintf("%s Scans: %s\n", glyph, strings.Join(scans, ", ")))
+		} else {
+			b.WriteString("No full table scans\n")
+		}
+
+		for _, warning := range plan.Warnings {
+			b.WriteString(fmt.Sprintf("%s %s\n", glyph, warning.Message))
+			if warning.Detail != "" {
+				b.WriteString(fmt.Sprintf("    %s\n", warning.Detail))
+			}
+		}
+
+		for _, index := range plan.MissingIndexes {
+			b.WriteString(fmt.Sprintf("%s Missing index on %s (%.0f%% impact): %s\n",
+				glyph, index.Table, index.Impact, missingIndexColumns(index)))
+		}
+
+		b.WriteString("\nOperators:\n")
+		for _, op := range plan.Operators {
+			line := strings.Repeat("  ", op.Depth+1) + op.Physical
+			if op.Table != "" {
+				line += " on " + op.Table
+			}
+			b.WriteString(fmt.Sprintf("%s  (%s rows)\n", line, formatEstimate(op.EstimatedRows)))
+		}
+	}
+
+	return b.String()
+}
+
+func missingIndexColumns(index database.MissingIndex) string {
+	columns := strings.Join(append(index.Equality, index.Inequality...), ", ")
+	if len(index.Include) > 0 {
+		columns += " INCLUDE " + strings.Join(index.Include, ", ")
+	}
+	return columns
+}
+
+// formatEstimate keeps whole estimates whole and rounds the rest.
+func formatEstimate(rows float64) string {
+	if rows == float64(int64(rows)) {
+		return strconv.FormatInt(int64(rows), 10)
+	}
+	return strconv.FormatFloat(rows, 'f', 1, 64)
+}
@@ -0,0 +1,169 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/storage"
+	"github.com/robertgouveia/do-my-job/tea"
+)
+
+const sandboxColour = "#8700AF"
+
+// sandbox is set while the tool runs in training mode. Every script then
+// runs against it, whatever server it names.
+var sandbox *database.Sandbox
+
+func sandboxPath() string {
+	return filepath.Join(storage.GetConfigDir(), "sandbox.db")
+}
+
+// SandboxMenu switches the tool into training mode: scripts run against a
+// local SQLite copy of the tables they change, the banner says so, and a
+// Sandbox menu can browse or reset the practice data.
+func SandboxMenu(mainMenu *tea.TeaModel) (*tea.TeaModel, error) {
+	sb, err := database.OpenSandbox(sandboxPath())
+	if err != nil {
+		return nil, err
+	}
+	sandbox = sb
+	database.SetDefault(sb)
+
+	mainMenu.Banner = sandboxBanner
+
+	status := mainMenu.Status
+	mainMenu.Status = func() []tea.StatusSegment {
+		var segments []tea.StatusSegment
+		if status != nil {
+			segments = status()
+		}
+		return append(segments, tea.StatusSegment{Label: "Mode", Value: "SANDBOX"})
+	}
+
+	sandboxMenu := tea.Create("Sandbox")
+	mainMenu.AddSubmenu("Sandbox", sandboxMenu)
+
+	sandboxMenu.AddTask("Browse Data", func(ctx context.Context, report func(tea.Progress)) tea.Result {
+		output, err := browseSandbox(ctx)
+		if err != nil {
+			return tea.Fail(err, nil)
+		}
+		return tea.Show(output)
+	})
+
+	sandboxMenu.AddGuardedMenuItem("Reset Sandbox", func() string { return "reset" }, func() tea.Result {
+		if err := sandbox.Reset(); err != nil {
+			return tea.Fail(err, nil)
+		}
+		return tea.Show("Sandbox reset to the sample data.")
+	})
+
+	return sandboxMenu, nil
+}
+
+// diagnoseSandbox stands in for diagnose in training mode, where every
+// script runs against the sandbox file rather than the server named, so
+// testing the connection must not dial the real server.
+func diagnoseSandbox(ctx context.Context, serverName string) []database.DiagnosticStep {
+	health := database.Ping(ctx, serverName)
+
+	step := database.DiagnosticStep{Name: "Sandbox", OK: health.Up, Duration: health.Latency}
+	if health.Up {
+		step.Detail = fmt.Sprintf("%s at %s", health.Version, sandbox.Path)
+	} else {
+		step.Detail = health.Err.Error()
+		step.Hint = "Reset the sandbox from the Sandbox menu or with 'sandbox reset'"
+	}
+	return []database.DiagnosticStep{step}
+}
+
+func sandboxBanner() string {
+	env := strings.ToUpper(storage.CurrentEnvironment())
+	label := fmt.Sprintf("SANDBOX - practice data only (playing %s)", env)
+
+	if tea.CurrentTheme().Plain {
+		return "[ " + label + " ]"
+	}
+
+	return lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 1).
+		Foreground(lipgloss.Color("#FFFFFF")).
+		Background(lipgloss.Color(sandboxColour)).
+		Render(label)
+}
+
+func browseSandbox(ctx context.Context) (string, error) {
+	conn, err := sandbox.Connect(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	var b strings.Builder
+	for _, table := range database.SandboxTables {
+		rows, err := conn.Query(ctx, "SELECT * FROM "+conn.Dialect().Quote(table), nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", table, err)
+		}
+
+		b.WriteString(table + "\n")
+		b.WriteString(strings.Repeat("=", len(table)) + "\n")
+		b.WriteString(formatRows(rows))
+		b.WriteString("\n")
+	}
+
+	return b.String(), nil
+}
+
+func formatRows(rows database.Rows) string {
+	widths := make([]int, len(rows.Columns))
+	for i, column := range rows.Columns {
+		widths[i] = len(column)
+	}
+	for _, row := range rows.Values {
+		for i, value := range row {
+			widths[i] = max(widths[i], len(fmt.Sprint(value)))
+		}
+	}
+
+	var b strings.Builder
+	line := func(values []string) {
+		for i, value := range values {
+			b.WriteString(fmt.Sprintf("%-*s  ", widths[i], value))
+		}
+		b.WriteString("\n")
+	}
+
+	line(rows.Columns)
+	for _, row := range rows.Values {
+		values := make([]string, len(row))
+		for i, value := range row {
+			values[i] = fmt.Sprint(value)
+		}
+		line(values)
+	}
+
+	return b.String()
+}
+
+// ResetSandboxCommand reseeds the sandbox from the command line and returns
+// the process exit code.
+func ResetSandboxCommand(w io.Writer) int {
+	sb, err := database.OpenSandbox(sandboxPath())
+	if err == nil {
+		err = sb.Reset()
+	}
+	if err != nil {
+		fmt.Fprintf(w, "Error resetting sandbox: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(w, "Sandbox reset: %s\n", sb.Path)
+	return 0
+}
@@ -1,10 +1,14 @@
 package menu
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/storage"
 	"github.com/robertgouveia/do-my-job/tea"
 )
 
@@ -29,14 +33,199 @@ type Script struct {
 	Params     []Param
 	Select     []Select
 	ServerName string
+	Servers    map[string]string
 	Statement  string
+	// Retry defaults to database.DefaultRetryPolicy. Use database.NoRetry
+	// for statements that are not safe to run twice.
+	Retry *database.RetryPolicy
+	// LockTimeout stops the statement waiting on other sessions' locks.
+	// It can be changed per script from the menu; zero waits forever.
+	LockTimeout time.Duration
+	// Snapshot backs up the rows the script changes before Execute runs
+	// it, so they can be put back with the restore command.
+	Snapshot *database.SnapshotSpec
+}
+
+func (s Script) retryPolicy() database.RetryPolicy {
+	if s.Retry != nil {
+		return *s.Retry
+	}
+	return database.DefaultRetryPolicy
+}
+
+func (s Script) lockTimeout() time.Duration {
+	if saved := loadScriptSettings(s.Title).LockTimeout; saved != "" {
+		if d, err := time.ParseDuration(saved); err == nil {
+			return d
+		}
+	}
+	return s.LockTimeout
+}
+
+// targetServer resolves the server the script should hit in the current
+// environment. Servers maps an environment to a server name; ServerName is
+// used when there is no override.
+func (s Script) targetServer() (string, storage.ServerConfig, error) {
+	return s.target(storage.LoadServerConfig)
+}
+
+// cachedTarget is targetServer for what is drawn on every render, using
+// the cached configs.
+func (s Script) cachedTarget() (string, storage.ServerConfig, error) {
+	return s.target(cachedServerConfig)
+}
+
+func (s Script) target(load func(string) (storage.ServerConfig, error)) (string, storage.ServerConfig, error) {
+	env := storage.CurrentEnvironment()
+
+	name := s.ServerName
+	if override, ok := s.Servers[env]; ok {
+		name = override
+	}
+
+	config, err := checkServer(name, load)
+	return name, config, err
+}
+
+// resolveServer loads the config for serverName and checks it is tagged
+// for the current environment.
+func resolveServer(serverName string) (storage.ServerConfig, error) {
+	return checkServer(serverName, storage.LoadServerConfig)
+}
+
+func checkServer(serverName string, load func(string) (storage.ServerConfig, error)) (storage.ServerConfig, error) {
+	env := storage.CurrentEnvironment()
+
+	if sandbox != nil {
+		// the sandbox stands in for every server in every environment, but
+		// keeps the prod confirmation so it can be practised too
+		return storage.ServerConfig{Host: sandbox.Path, Dialect: storage.DialectSQLite, Environment: env}, nil
+	}
+
+	config, err := load(serverName)
+	if err != nil {
+		return config, err
+	}
+
+	if config.Env() != env {
+		return config, fmt.Errorf("%s is tagged %s but the current environment is %s",
+			serverName, strings.ToUpper(config.Env()), strings.ToUpper(env))
+	}
+
+	return config, nil
+}
+
+// configWarning is shown against the script in the menu when its target
+// server could not be connected to as configured.
+func (s Script) configWarning() string {
+	name, config, err := s.cachedTarget()
+	switch {
+	case config.IsZero():
+		return name + " not configured"
+	case len(config.Missing()) > 0:
+		return name + " config incomplete"
+	case err != nil:
+		return "environment mismatch"
+	}
+	return ""
+}
+
+// namedParams collects the values set in the menus, along with a
+// description of them for the output screen.
+func (s Script) namedParams() (map[string]interface{}, string) {
+	namedParams := make(map[string]interface{})
+	str := ""
+
+	for _, param := range s.Params {
+		if param.Name != "" && param.Value != nil {
+			namedParams[param.Name] = param.Value
+			str += fmt.Sprintf(" [%s:%v] ", param.Title, param.Value)
+		}
+	}
+
+	for _, option := range s.Select {
+		if option.Name != "" && option.Selected != nil {
+			var paramValue any
+			var displayValue any
+
+			if index, ok := option.Selected.(int); ok {
+				if index >= 0 && index < len(option.Values) {
+					displayValue = option.Values[index]
+
+					if option.UseIndex {
+						if len(option.ValueMap) > index {
+							paramValue = option.ValueMap[index]
+						} else {
+							paramValue = index + 1
+						}
+					} else {
+						paramValue = option.Values[index]
+					}
+				}
+			} else if strValue, ok := option.Selected.(string); ok {
+				displayValue = strValue
+				paramValue = strValue
+
+				if option.UseIndex {
+					for i, v := range option.Values {
+						if v == strValue {
+							if len(option.ValueMap) > i {
+								paramValue = option.ValueMap[i]
+							} else {
+								paramValue = i + 1
+							}
+							break
+						}
+					}
+				}
+			} else {
+				displayValue = option.Selected
+				paramValue = option.Selected
+			}
+
+			namedParams[option.Name] = paramValue
+			str += fmt.Sprintf(" [%s:%v] ", option.Title, displayValue)
+		}
+	}
+
+	return namedParams, str
 }
 
 func ScriptMenu(mainMenu *tea.TeaModel) *tea.TeaModel {
 	scriptMenu := tea.Create("Scripts")
+	scriptMenu.OnOpen = checkPermissions
 	mainMenu.AddSubmenu("Scripts", scriptMenu)
 
-	scripts := []Script{
+	for _, script := range scripts() {
+		scriptMenu.AddSubmenu(script.Title, scriptTemplate(script))
+	}
+
+	scriptMenu.AddMenuItem("Run History", func() tea.Result {
+		entries, err := loadHistory(historyLimit)
+		if err != nil {
+			return tea.Fail(err, nil)
+		}
+		return tea.Show(formatHistory(entries))
+	})
+
+	scriptMenu.AddTask("Validate Scripts", validateTask)
+	scriptMenu.AddSubmenu("Bulk Import", importTemplate())
+
+	scriptMenu.AddMenuItem("Snapshots", func() tea.Result {
+		snapshots, err := listSnapshots(snapshotLimit)
+		if err != nil {
+			return tea.Fail(err, nil)
+		}
+		return tea.Show(formatSnapshots(snapshots))
+	})
+
+	return scriptMenu
+}
+
+// scripts returns fresh copies of the scripts, so values set in one menu
+// do not leak into another.
+func scripts() []Script {
+	return []Script{
 		{
 			Title: "Dispute Status Change",
 			Params: []Param{
@@ -69,6 +258,7 @@ func ScriptMenu(mainMenu *tea.TeaModel) *tea.TeaModel {
 			},
 			ServerName: "RKW Data Warehouse",
 			Statement:  database.DisputeChange,
+			Snapshot:   &database.SnapshotSpec{},
 		},
 		{
 			Title: "Shipping Agent Service Change",
@@ -78,117 +268,202 @@ func ScriptMenu(mainMenu *tea.TeaModel) *tea.TeaModel {
 					Name:  "OrderNo",
 				},
 			},
-			ServerName: "RKW Level 1",
-			Statement:  database.ShippingChange,
+			ServerName:  "RKW Level 1",
+			Statement:   database.ShippingChange,
+			LockTimeout: 10 * time.Second,
+			Snapshot:    &database.SnapshotSpec{},
 		},
 	}
-
-	for _, script := range scripts {
-		scriptMenu.AddSubmenu(script.Title, scriptTemplate(script.Title, script.Params, script.Select, script.Statement, script.ServerName))
-	}
-
-	return scriptMenu
 }
 
-func scriptTemplate(title string, params []Param, s []Select, statement, sname string) *tea.TeaModel {
-	rkwScriptMenu := tea.Create(title)
+func scriptTemplate(script Script) *tea.TeaModel {
+	rkwScriptMenu := tea.Create(script.Title)
+	params := script.Params
+	s := script.Select
 
-	for i := range params {
-		param := &params[i]
+	rkwScriptMenu.Status = func() []tea.StatusSegment {
+		sname, _, err := script.cachedTarget()
+		lockTimeout := tea.StatusSegment{Label: "Lock timeout", Value: formatLockTimeout(script.lockTimeout())}
+		if sandbox != nil {
+			return []tea.StatusSegment{{Label: "Server", Value: sname + " (sandbox)"}, connectionSegment(sname), lockTimeout}
+		}
 
-		rkwScriptMenu.AddTextInput(
-			fmt.Sprintf("Set %s", param.Title),
-			"Enter Param:",
-			fmt.Sprintf("The current value is %v", param.Value),
-			func(input string) {
-				param.Value = input
-				fmt.Printf("%s set to: %s and saved\n", param.Title, input)
-			},
-		)
+		server := serverSegment(sname)
+		if err != nil && !server.Warn {
+			server.Value += " - " + err.Error()
+			server.Warn = true
+		}
+		return []tea.StatusSegment{server, connectionSegment(sname), lockTimeout}
+	}
+	rkwScriptMenu.Warning = script.configWarning
+	rkwScriptMenu.Disabled = script.permissionReason
+
+	if len(params) > 0 {
+		rkwScriptMenu.AddForm("Set Parameters", func() []tea.FormField {
+			fields := make([]tea.FormField, len(params))
+			for i, param := range params {
+				value := ""
+				if param.Value != nil {
+					value = fmt.Sprintf("%v", param.Value)
+				}
+				fields[i] = tea.FormField{Label: param.Title, Value: value, Validate: tea.Required}
+			}
+			return fields
+		}, func(values []string) tea.Result {
+			for i := range params {
+				params[i].Value = values[i]
+			}
+			return tea.None()
+		})
 	}
 
 	for i := range s {
 		rkwScriptMenu.AddSubmenu(s[i].Title, selectTemplate(&s[i]))
 	}
 
-	rkwScriptMenu.AddMenuItem("Execute", func() string {
-		str := "Executing: "
+	rkwScriptMenu.AddForm("Set Lock Timeout", func() []tea.FormField {
+		value := ""
+		if d := script.lockTimeout(); d > 0 {
+			value = d.String()
+		}
+		return []tea.FormField{{Label: "Lock Timeout", Value: value, Placeholder: "e.g. 10s, blank to wait", Validate: validateLockTimeout}}
+	}, func(values []string) tea.Result {
+		timeout := strings.TrimSpace(values[0])
+		if timeout == "" {
+			// remember the choice to wait, rather than falling back to the default
+			timeout = "0s"
+		}
+		if err := saveLockTimeout(script.Title, timeout); err != nil {
+			return tea.Fail(err, nil)
+		}
+		return tea.None()
+	})
 
-		namedParams := make(map[string]interface{})
+	confirmProd := func() string {
+		sname, config, err := script.targetServer()
+		if err == nil && config.Env() == storage.EnvProd {
+			return sname
+		}
+		return ""
+	}
 
-		for _, param := range params {
-			if param.Name != "" && param.Value != nil {
-				namedParams[param.Name] = param.Value
-				str += fmt.Sprintf(" [%s:%v] ", param.Title, param.Value)
-			}
+	rkwScriptMenu.AddTask("Show Plan", func(ctx context.Context, report func(tea.Progress)) tea.Result {
+		sname, _, err := script.targetServer()
+		if err != nil {
+			return tea.Fail(err, nil)
 		}
 
-		for _, option := range s {
-			if option.Name != "" && option.Selected != nil {
-				var paramValue any
-				var displayValue any
+		namedParams, _ := script.namedParams()
 
-				if index, ok := option.Selected.(int); ok {
-					if index >= 0 && index < len(option.Values) {
-						displayValue = option.Values[index]
+		report(tea.Progress{Fraction: -1, Message: fmt.Sprintf("Fetching the estimated plan from %s...", sname)})
+		plans, err := database.ShowPlan(ctx, database.Default(), sname, script.Statement, namedParams)
+		if err != nil {
+			return tea.Fail(fmt.Errorf("failed to fetch plan from %s: %w", sname, err), nil)
+		}
 
-						if option.UseIndex {
-							if len(option.ValueMap) > index {
-								paramValue = option.ValueMap[index]
-							} else {
-								paramValue = index + 1
-							}
-						} else {
-							paramValue = option.Values[index]
-						}
-					}
-				} else if strValue, ok := option.Selected.(string); ok {
-					displayValue = strValue
-					paramValue = strValue
+		return tea.Show(formatPlans(plans))
+	})
 
-					if option.UseIndex {
-						for i, v := range option.Values {
-							if v == strValue {
-								if len(option.ValueMap) > i {
-									paramValue = option.ValueMap[i]
-								} else {
-									paramValue = i + 1
-								}
-								break
-							}
-						}
-					}
-				} else {
-					displayValue = option.Selected
-					paramValue = option.Selected
-				}
+	if database.IsWrite(script.Statement) {
+		rkwScriptMenu.AddTask("Check Locks", func(ctx context.Context, report func(tea.Progress)) tea.Result {
+			sname, _, err := script.targetServer()
+			if err != nil {
+				return tea.Fail(err, nil)
+			}
 
-				namedParams[option.Name] = paramValue
-				str += fmt.Sprintf(" [%s:%v] ", option.Title, displayValue)
+			tables := database.StatementTables(script.Statement)
+			report(tea.Progress{Fraction: -1, Message: fmt.Sprintf("Checking locks on %s...", strings.Join(tables, ", "))})
+			locks, err := database.CheckLocks(ctx, database.Default(), sname, tables)
+			if err != nil {
+				return tea.Fail(fmt.Errorf("failed to check locks on %s: %w", sname, err), nil)
 			}
-		}
 
-		db, err := database.Connect(sname)
+			return tea.Show(formatLocks(locks))
+		})
+	}
+
+	rkwScriptMenu.AddGuardedTask("Execute", confirmProd, func(ctx context.Context, report func(tea.Progress)) tea.Result {
+		sname, config, err := script.targetServer()
 		if err != nil {
-			log.Fatalf("Error connecting to DB: %s", err.Error())
+			return tea.Fail(err, nil)
 		}
 
-		res, debugInfo, err := database.ExecuteWithNamedParams(db, statement, namedParams)
+		namedParams, str := script.namedParams()
+		str = "Executing: " + str
+
+		note := ""
+		if database.IsWrite(script.Statement) {
+			report(tea.Progress{Fraction: -1, Message: fmt.Sprintf("Checking %s for blocking sessions...", sname)})
+			if note, err = checkLocks(ctx, sname, script.Statement, script.lockTimeout()); err != nil {
+				return tea.Fail(err, nil)
+			}
+		}
+
+		snapshot := ""
+		if script.Snapshot != nil {
+			report(tea.Progress{Fraction: -1, Message: fmt.Sprintf("Backing up the rows on %s...", sname)})
+			path, rows, err := takeSnapshot(ctx, sname, script, namedParams)
+			if err != nil {
+				return tea.Fail(fmt.Errorf("not run, the snapshot failed: %w", err), nil)
+			}
+			snapshot = filepath.Base(path)
+			note = strings.TrimSpace(note + fmt.Sprintf("\nBacked up %d rows first, see Snapshots to restore them.", rows))
+		}
+
+		report(tea.Progress{Fraction: -1, Message: fmt.Sprintf("Running against %s...", sname)})
+
+		ctx = database.WithLockTimeout(ctx, script.lockTimeout())
+		result, err := executeScript(ctx, sname, script.Statement, namedParams, script.retryPolicy(), func(attempt int, err error, wait time.Duration) {
+			report(tea.Progress{Fraction: -1, Message: fmt.Sprintf(
+				"Attempt %d on %s failed with a transient error, retrying in %s...\n%v",
+				attempt, sname, wait.Round(time.Millisecond), err)})
+		})
+
+		entry := historyEntry{
+			Time:        time.Now(),
+			Script:      script.Title,
+			Server:      sname,
+			Environment: config.Env(),
+			Params:      result.Params,
+			Rows:        result.Rows,
+			Retries:     result.Retries,
+			Snapshot:    snapshot,
+		}
 		if err != nil {
-			log.Fatalf("Error executing DB statement: %s, Variables: %s", err.Error(), debugInfo)
+			entry.Error = err.Error()
 		}
+		recordHistory(entry)
 
-		rows, err := res.RowsAffected()
 		if err != nil {
-			log.Fatalf("Error fetching rows affected: %s", err.Error())
+			if result.Retries > 0 {
+				err = fmt.Errorf("%w\n(gave up after %d retries)", err, result.Retries)
+			}
+			if note != "" {
+				err = fmt.Errorf("%w\n%s", err, note)
+			}
+			return tea.Fail(err, nil)
 		}
 
-		return str + fmt.Sprintf(" Rows Affected: %d Params: %s", rows, debugInfo)
+		output := str + fmt.Sprintf(" Rows Affected: %d Retries: %d Params: %s", result.Rows, result.Retries, result.Params)
+		if note != "" {
+			output += "\n" + note
+		}
+		return tea.Show(output)
 	})
 
 	return rkwScriptMenu
 }
 
+func executeScript(ctx context.Context, serverName, statement string, namedParams map[string]interface{}, policy database.RetryPolicy, onRetry func(int, error, time.Duration)) (database.ExecResult, error) {
+	result, err := database.ExecWithRetry(ctx, database.Default(), serverName, statement, namedParams, policy, onRetry)
+	recordConnection(serverName, result.Connected)
+	if err != nil {
+		return result, fmt.Errorf("error executing statement on %s: %w\nVariables: %s", serverName, err, result.Params)
+	}
+
+	return result, nil
+}
+
 func selectTemplate(s *Select) *tea.TeaModel {
 	rkwSelectMenu := tea.Create(s.Title)
 
@@ -196,9 +471,9 @@ func selectTemplate(s *Select) *tea.TeaModel {
 		value := option
 		index := i
 
-		rkwSelectMenu.AddMenuItem(value, func() string {
+		rkwSelectMenu.AddMenuItem(value, func() tea.Result {
 			s.Selected = index
-			return "back"
+			return tea.Back()
 		})
 	}
 
@@ -1,10 +1,13 @@
 package menu
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/blang/semver"
@@ -16,58 +19,143 @@ import (
 )
 
 const (
-	currentVersion = "0.2.1"
-	repoSlug       = "robertgouveia/rkw-software-support"
+	currentVersion    = "0.2.1"
+	repoSlug          = "robertgouveia/rkw-software-support"
+	diagnosticTimeout = 30 * time.Second
 )
 
+// diagnose probes DNS and TCP directly rather than through a Connector,
+// so tests swap it out instead.
+var diagnose = database.Diagnose
+
+// knownServers are the servers the tool can be configured for, by menu
+// title and the name their config is saved under.
+var knownServers = []struct{ title, name string }{
+	{"RKW Data Warehouse", "RKW Data Warehouse"},
+	{"NAVSLAT02", "NAVSQLAT02"},
+	{"RKW Level 1", "RKW Level 1"},
+	{"RKW Level 3 VIC", "RKW Level 3 VIC"},
+	{"RKW Level 3 STONE", "RKW Level 3 STONE"},
+}
+
+// migrateLegacyServers moves configs saved before the servers namespace
+// existed into it, which loading them does, so listing finds them.
+func migrateLegacyServers() {
+	for _, server := range knownServers {
+		if _, err := storage.LoadServerConfig(server.name); err != nil {
+			log.Printf("Warning: Could not load saved config for %s: %v", server.name, err)
+		}
+	}
+}
+
 func ServerMenu(mainMenu *tea.TeaModel) *tea.TeaModel {
 	configureServerMenu := tea.Create("Configure Servers")
 	mainMenu.AddSubmenu("Configure Servers", configureServerMenu)
 
-	configureServerMenu.AddSubmenu("RKW Data Warehouse", serverTemplate("RKW Data Warehouse", "RKW Data Warehouse"))
-	configureServerMenu.AddSubmenu("NAVSLAT02", serverTemplate("NAVSQLAT02", "NAVSQLAT02"))
-	configureServerMenu.AddSubmenu("RKW Level 1", serverTemplate("RKW Level 1", "RKW Level 1"))
-	configureServerMenu.AddSubmenu("RKW Level 3 VIC", serverTemplate("RKW Level 3 VIC", "RKW Level 3 VIC"))
-	configureServerMenu.AddSubmenu("RKW Level 3 STONE", serverTemplate("RKW Level 3 STONE", "RKW Level 3 STONE"))
+	for _, server := range knownServers {
+		configureServerMenu.AddSubmenu(server.title, serverTemplate(server.name, server.name))
+	}
+
+	mainMenu.AddModel("Server Status", newStatusModel)
+
+	mainMenu.AddTask("Update", func(ctx context.Context, report func(tea.Progress)) tea.Result {
+		report(tea.Progress{Fraction: -1, Message: "Checking for updates..."})
 
-	mainMenu.AddMenuItem("Update", func() string {
 		v := semver.MustParse(currentVersion)
 		updater, err := selfupdate.NewUpdater(selfupdate.Config{})
 		if err != nil {
-			return fmt.Sprintf("Error creating updater: %s", err.Error())
+			return tea.Show(fmt.Sprintf("Error creating updater: %s", err.Error()))
 		}
 
 		latest, found, err := updater.DetectLatest(repoSlug)
 		if err != nil {
-			return fmt.Sprintf("Error checking for updates: %s", err.Error())
+			return tea.Show(fmt.Sprintf("Error checking for updates: %s", err.Error()))
 		}
 
 		if !found || latest.Version.LTE(v) {
-			return "You're up-to-date: " + currentVersion
+			return tea.Show("You're up-to-date: " + currentVersion)
+		}
+
+		if ctx.Err() != nil {
+			return tea.Show("Update cancelled.")
 		}
 
-		fmt.Printf("New version found: %s\nUpdating...\n", latest.Version)
+		report(tea.Progress{Fraction: -1, Message: fmt.Sprintf("New version found: %s\nDownloading and installing...", latest.Version)})
 		if err := updater.UpdateTo(latest, os.Args[0]); err != nil {
-			return fmt.Sprintf("Update failed: %s", err.Error())
+			return tea.Show(fmt.Sprintf("Update failed: %s", err.Error()))
 		}
 
-		return fmt.Sprintf("Successfully updated to version: %s", latest.Version)
+		return tea.Show(fmt.Sprintf("Successfully updated to version: %s", latest.Version))
 	})
 
-	mainMenu.AddMenuItem("About", func() string {
-		return `
+	mainMenu.AddMenuItem("About", func() tea.Result {
+		return tea.Show(`
 RKW Support Tool v0.1
 =============================
 A simple tool to execute automations to make your life easier
 Built with Golang
 Robert Gouveia
 
-Configurations are stored in: ` + storage.GetConfigDir()
+Configurations are stored in: ` + storage.GetConfigDir())
 	})
 
 	return configureServerMenu
 }
 
+func formatDiagnostics(serverName string, steps []database.DiagnosticStep) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("\nConnection Diagnostics: %s\n", serverName))
+	b.WriteString("=======================\n")
+
+	for _, step := range steps {
+		status := "PASS"
+		switch {
+		case step.Unverified:
+			status = "----"
+		case !step.OK:
+			status = "FAIL"
+		}
+
+		b.WriteString(fmt.Sprintf("[%s] %-20s %-8s %s\n", status, step.Name, step.Duration.Round(time.Millisecond), step.Detail))
+		if step.Hint != "" {
+			b.WriteString(fmt.Sprintf("       Hint: %s\n", step.Hint))
+		}
+	}
+
+	if len(steps) > 0 && steps[len(steps)-1].OK {
+		b.WriteString("\nAll checks passed.\n")
+	}
+
+	return b.String()
+}
+
+func validatePort(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("must be a number between 1 and 65535")
+	}
+	return nil
+}
+
+func validateDialect(value string) error {
+	if !storage.IsDialect(value) {
+		return fmt.Errorf("must be one of %s", strings.Join(storage.Dialects, ", "))
+	}
+	return nil
+}
+
+func maskPassword(password string) string {
+	if password == "" {
+		return "[Not Set]"
+	}
+	return strings.Repeat("*", 8)
+}
+
 func serverTemplate(serverName, title string) *tea.TeaModel {
 	config, err := storage.LoadServerConfig(serverName)
 	if err != nil {
@@ -75,121 +163,71 @@ func serverTemplate(serverName, title string) *tea.TeaModel {
 	}
 
 	rkwServerMenu := tea.Create(title)
+	rkwServerMenu.Status = func() []tea.StatusSegment {
+		return []tea.StatusSegment{serverSegment(serverName), connectionSegment(serverName)}
+	}
 
-	rkwServerMenu.AddTextInput(
-		"Set Host",
-		"Enter server hostname or IP address:",
-		fmt.Sprintf("The hostname or IP address of the RKW data warehouse server\nCurrent value: %s",
-			lib.StringOrDefault(config.Host, "[Not Set]")),
-		func(input string) {
-			config.Host = input
-			config.LastUpdated = time.Now()
-
-			// Save after update
-			if err := storage.SaveServerConfig(serverName, config); err != nil {
-				log.Printf("Failed to save config: %v", err)
-			} else {
-				fmt.Printf("Host set to: %s and saved\n", input)
-			}
-		},
-	)
-
-	rkwServerMenu.AddTextInput(
-		"Set Port",
-		"Enter server port:",
-		fmt.Sprintf("The port number to connect to (e.g., 5432 for PostgreSQL)\nCurrent value: %s",
-			lib.StringOrDefault(config.Port, "[Not Set]")),
-		func(input string) {
-			config.Port = input
-			config.LastUpdated = time.Now()
-
-			if err := storage.SaveServerConfig(serverName, config); err != nil {
-				log.Printf("Failed to save config: %v", err)
-			} else {
-				fmt.Printf("Port set to: %s and saved\n", input)
-			}
-		},
-	)
-
-	rkwServerMenu.AddTextInput(
-		"Set Username",
-		"Enter username:",
-		fmt.Sprintf("Database username for authentication\nCurrent value: %s",
-			lib.StringOrDefault(config.Username, "[Not Set]")),
-		func(input string) {
-			config.Username = input
-			config.LastUpdated = time.Now()
-
-			if err := storage.SaveServerConfig(serverName, config); err != nil {
-				log.Printf("Failed to save config: %v", err)
-			} else {
-				fmt.Printf("Username set to: %s and saved\n", input)
-			}
-		},
-	)
-
-	rkwServerMenu.AddTextInput(
-		"Set Password",
-		"Enter password:",
-		fmt.Sprintf("Database password for authentication\nCurrent value: %s",
-			lib.StringOrDefault(config.Password, "[Not Set]")),
-		func(input string) {
-			config.Password = input
-			config.LastUpdated = time.Now()
-
-			if err := storage.SaveServerConfig(serverName, config); err != nil {
-				log.Printf("Failed to save config: %v", err)
-			} else {
-				fmt.Printf("Password set to: %s and saved\n", input)
-			}
-		},
-	)
-
-	rkwServerMenu.AddTextInput(
-		"Set Database",
-		"Enter database name:",
-		fmt.Sprintf("The name of the database to connect to\nCurrent value: %s",
-			lib.StringOrDefault(config.Database, "[Not Set]")),
-		func(input string) {
-			config.Database = input
-			config.LastUpdated = time.Now()
-
-			if err := storage.SaveServerConfig(serverName, config); err != nil {
-				log.Printf("Failed to save config: %v", err)
-			} else {
-				fmt.Printf("Database set to: %s and saved\n", input)
-			}
-		},
-	)
-
-	rkwServerMenu.AddMenuItem("Test Connection", func() string {
-		if config.Host == "" || config.Port == "" || config.Username == "" || config.Database == "" {
-			return "Error: Please configure all server settings first."
-		}
-
-		_, err := database.Connect(serverName)
+	// opened is the config the form was filled from, so a save can tell
+	// whether another instance changed it in the meantime
+	var opened storage.ServerConfig
+	rkwServerMenu.AddForm("Edit Configuration", func() []tea.FormField {
+		if latest, err := storage.LoadServerConfig(serverName); err == nil {
+			config = latest
+		}
+		opened = config
+
+		return []tea.FormField{
+			{Label: "Host", Value: config.Host, Placeholder: "hostname, IP or SQLite file path", Validate: tea.Required},
+			{Label: "Port", Value: config.Port, Placeholder: "default for the dialect", Validate: validatePort},
+			{Label: "Username", Value: config.Username, Placeholder: "database login (not used by sqlite)"},
+			{Label: "Password", Value: config.Password, Masked: true},
+			{Label: "Database", Value: config.Database, Placeholder: "database name (not used by sqlite)"},
+			{Label: "Dialect", Value: config.DialectName(), Placeholder: strings.Join(storage.Dialects, ", "), Validate: validateDialect},
+		}
+	}, func(values []string) tea.Result {
+		updated, err := storage.EditServerConfig(serverName, opened, func(c *storage.ServerConfig) {
+			c.Host = values[0]
+			c.Port = values[1]
+			c.Username = values[2]
+			c.Password = values[3]
+			c.Database = values[4]
+			c.Dialect = values[5]
+		})
+		forgetServerConfigs()
+		if errors.Is(err, storage.ErrModified) {
+			return tea.Fail(fmt.Errorf("not saved, the configuration was changed elsewhere since the form was opened; reopen it to see the changes: %w", err), nil)
+		}
 		if err != nil {
-			return fmt.Sprintf("Error: %s", err.Error())
+			return tea.Fail(fmt.Errorf("failed to save config: %w", err), nil)
 		}
 
-		return fmt.Sprintf(`
-Connection Test Results:
-=======================
-Host: %s
-Port: %s
-Username: %s
-Database: %s
-Status: Simulated connection successful
-		`, config.Host, config.Port, config.Username, config.Database)
+		config = updated
+		return tea.Show(fmt.Sprintf("Configuration for %s saved.", serverName))
+	})
+
+	rkwServerMenu.AddSubmenu("Set Environment", environmentSelectTemplate(serverName, &config))
+
+	rkwServerMenu.AddTask("Test Connection", func(ctx context.Context, report func(tea.Progress)) tea.Result {
+		ctx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+		defer cancel()
+
+		run := diagnose
+		if sandbox != nil {
+			run = diagnoseSandbox
+		}
+		steps := run(ctx, serverName)
+		recordConnection(serverName, len(steps) > 0 && steps[len(steps)-1].OK)
+
+		return tea.Show(formatDiagnostics(serverName, steps))
 	})
 
-	rkwServerMenu.AddMenuItem("View Configuration", func() string {
+	rkwServerMenu.AddMenuItem("View Configuration", func() tea.Result {
 		lastUpdated := "Never"
 		if !config.LastUpdated.IsZero() {
 			lastUpdated = config.LastUpdated.Format(time.RFC1123)
 		}
 
-		return fmt.Sprintf(`
+		return tea.Show(fmt.Sprintf(`
 Current Configuration:
 =====================
 Host: %s
@@ -197,34 +235,36 @@ Port: %s
 Username: %s
 Password: %s
 Database: %s
+Dialect: %s
+Environment: %s
 Last Updated: %s
 Config File: %s
 			`,
 			lib.StringOrDefault(config.Host, "[Not Set]"),
 			lib.StringOrDefault(config.Port, "[Not Set]"),
 			lib.StringOrDefault(config.Username, "[Not Set]"),
-			lib.StringOrDefault(config.Password, "[Not Set]"),
+			maskPassword(config.Password),
 			lib.StringOrDefault(config.Database, "[Not Set]"),
+			config.DialectName(),
+			strings.ToUpper(config.Env()),
 			lastUpdated,
-			filepath.Join(storage.GetConfigDir(), serverName+".json"),
-		)
+			storage.ServerConfigLocation(serverName),
+		))
 	})
 
-	rkwServerMenu.AddMenuItem("Delete Saved Configuration", func() string {
-		configPath := filepath.Join(storage.GetConfigDir(), serverName+".json")
-
-		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			return "No saved configuration found."
+	rkwServerMenu.AddMenuItem("Delete Saved Configuration", func() tea.Result {
+		err := storage.DeleteServerConfig(serverName)
+		forgetServerConfigs()
+		if errors.Is(err, storage.ErrNotFound) {
+			return tea.Show("No saved configuration found.")
 		}
-
-		err := os.Remove(configPath)
 		if err != nil {
-			return fmt.Sprintf("Error deleting configuration: %v", err)
+			return tea.Show(fmt.Sprintf("Error deleting configuration: %v", err))
 		}
 
 		config = storage.ServerConfig{}
 
-		return "Configuration successfully deleted."
+		return tea.Show("Configuration successfully deleted.")
 	})
 
 	return rkwServerMenu
@@ -0,0 +1,192 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/storage"
+)
+
+const snapshotLimit = 20
+
+func snapshotDir() string {
+	return filepath.Join(storage.GetConfigDir(), "snapshots")
+}
+
+// takeSnapshot backs up the rows script is about to change and returns
+// where they were saved and how many there were.
+func takeSnapshot(ctx context.Context, serverName string, script Script, params map[string]interface{}) (string, int, error) {
+	snapshot, err := database.TakeSnapshot(ctx, database.Default(), serverName, script.Statement, *script.Snapshot, params)
+	if err != nil {
+		return "", 0, err
+	}
+	snapshot.Sandbox = sandbox != nil
+
+	path, err := snapshot.Save(snapshotDir())
+	if err != nil {
+		return "", 0, err
+	}
+	return path, len(snapshot.Rows), nil
+}
+
+type savedSnapshot struct {
+	Name     string
+	Snapshot database.Snapshot
+	Err      error
+}
+
+// listSnapshots returns up to limit snapshots, newest first. The file
+// names start with the time they were taken, so they sort by age.
+func listSnapshots(limit int) ([]savedSnapshot, error) {
+	entries, err := os.ReadDir(snapshotDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json.gz") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	if len(names) > limit {
+		names = names[:limit]
+	}
+
+	snapshots := make([]savedSnapshot, len(names))
+	for i, name := range names {
+		snapshots[i].Name = name
+		snapshots[i].Snapshot, snapshots[i].Err = database.LoadSnapshot(filepath.Join(snapshotDir(), name))
+	}
+	return snapshots, nil
+}
+
+func formatSnapshots(snapshots []savedSnapshot) string {
+	if len(snapshots) == 0 {
+		return "No snapshots have been taken yet.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Snapshots are kept in %s\n", snapshotDir()))
+	for _, s := range snapshots {
+		b.WriteString("\n" + s.Name + "\n")
+		if s.Err != nil {
+			b.WriteString(fmt.Sprintf("  could not be read: %v\n", s.Err))
+			continue
+		}
+
+		target := s.Snapshot.Server
+		if s.Snapshot.Sandbox {
+			target += " (sandbox)"
+		}
+		b.WriteString(fmt.Sprintf("  %s  %d rows of %s on %s\n", s.Snapshot.Taken.Local().Format("02 Jan 15:04:05"), len(s.Snapshot.Rows), s.Snapshot.Table, target))
+		b.WriteString(fmt.Sprintf("  where %s\n", s.Snapshot.Where))
+	}
+	b.WriteString("\nRestore one with: do-my-job restore <snapshot> [--merge] [--confirm <server>]\n")
+
+	return b.String()
+}
+
+// RestoreCommand puts a snapshot back on the server it was taken from and
+// returns the process exit code. With no snapshot named it lists them.
+// --merge updates rows that still exist instead of inserting them all,
+// which snapshots taken before an UPDATE always do. Restoring to a prod
+// server needs --confirm followed by its name, as the menus ask for.
+func RestoreCommand(w io.Writer, args []string) int {
+	var name, confirm string
+	merge := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--merge":
+			merge = true
+		case arg == "--confirm":
+			if i+1 == len(args) {
+				fmt.Fprintln(w, "--confirm needs the name of the server")
+				return 2
+			}
+			i++
+			confirm = args[i]
+		case name == "" && !strings.HasPrefix(arg, "-"):
+			name = arg
+		default:
+			fmt.Fprintf(w, "Unknown argument: %s\n", arg)
+			return 2
+		}
+	}
+
+	if name == "" {
+		snapshots, err := listSnapshots(snapshotLimit)
+		if err != nil {
+			fmt.Fprintln(w, err)
+			return 1
+		}
+		fmt.Fprint(w, formatSnapshots(snapshots))
+		return 0
+	}
+
+	path := name
+	if _, err := os.Stat(path); err != nil {
+		path = filepath.Join(snapshotDir(), name)
+	}
+	snapshot, err := database.LoadSnapshot(path)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+
+	connector := database.Default()
+	var config storage.ServerConfig
+	if snapshot.Sandbox {
+		sb, err := database.OpenSandbox(sandboxPath())
+		if err != nil {
+			fmt.Fprintln(w, err)
+			return 1
+		}
+		connector = sb
+	} else if config, err = resolveServer(snapshot.Server); err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	} else if config.Env() == storage.EnvProd && confirm != snapshot.Server {
+		fmt.Fprintf(w, "Not restored, %s is a PROD server. To write to it, run again with --confirm %q\n", snapshot.Server, snapshot.Server)
+		return 1
+	}
+
+	merge = merge || snapshot.MergeByDefault()
+	mode := "insert"
+	if merge {
+		mode = "merge"
+	}
+	restored, err := database.RestoreSnapshot(context.Background(), connector, snapshot, merge)
+
+	entry := historyEntry{
+		Time:        time.Now(),
+		Script:      "Restore snapshot of " + snapshot.Table,
+		Server:      snapshot.Server,
+		Environment: config.Env(),
+		Params:      fmt.Sprintf("[File:%s] [Mode:%s]", filepath.Base(path), mode),
+		Rows:        restored,
+		Snapshot:    filepath.Base(path),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	recordHistory(entry)
+
+	if err != nil {
+		fmt.Fprintf(w, "Failed to restore %s on %s: %v\n", snapshot.Table, snapshot.Server, err)
+		return 1
+	}
+	fmt.Fprintf(w, "Restored %d rows into %s on %s.\n", restored, snapshot.Table, snapshot.Server)
+	return 0
+}
@@ -0,0 +1,234 @@
+package menu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	bubble "github.com/charmbracelet/bubbletea"
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/storage"
+	"github.com/robertgouveia/do-my-job/tea"
+)
+
+const (
+	healthNamespace = "health"
+	pingTimeout     = 5 * time.Second
+	refreshInterval = 30 * time.Second
+)
+
+type serverStatus struct {
+	database.Health
+	LastSuccess time.Time
+}
+
+type healthRecord struct {
+	LastSuccess time.Time `json:"last_success"`
+}
+
+// checkServers pings every configured server and remembers when each one
+// last answered, so the dashboard and CLI can show it across runs.
+func checkServers(ctx context.Context) ([]serverStatus, error) {
+	migrateLegacyServers()
+
+	names, err := storage.ListServerConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	records := storage.Default().Namespace(healthNamespace)
+
+	var statuses []serverStatus
+	for _, health := range database.PingAll(ctx, names, pingTimeout) {
+		var record healthRecord
+		if err := records.Load(health.Server, &record); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Warning: could not read last success for %s: %v", health.Server, err)
+		}
+
+		recordConnection(health.Server, health.Up)
+		if health.Up {
+			record.LastSuccess = health.CheckedAt
+			if err := records.Save(health.Server, record); err != nil {
+				log.Printf("Warning: could not record last success for %s: %v", health.Server, err)
+			}
+		}
+
+		statuses = append(statuses, serverStatus{Health: health, LastSuccess: record.LastSuccess})
+	}
+
+	return statuses, nil
+}
+
+func formatStatusTable(statuses []serverStatus) string {
+	if len(statuses) == 0 {
+		return "No servers configured.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%-22s %-6s %-10s %-32s %s\n", "SERVER", "STATUS", "LATENCY", "VERSION", "LAST SUCCESS"))
+
+	for _, s := range statuses {
+		status, latency, version := "DOWN", "-", "-"
+		if s.Up {
+			status = "UP"
+			latency = s.Latency.Round(time.Millisecond).String()
+			version = s.Version
+		}
+
+		lastSuccess := "Never"
+		if !s.LastSuccess.IsZero() {
+			lastSuccess = s.LastSuccess.Format("02 Jan 15:04:05")
+		}
+
+		b.WriteString(fmt.Sprintf("%-22s %-6s %-10s %-32s %s\n", s.Server, status, latency, version, lastSuccess))
+		if s.Err != nil {
+			b.WriteString(fmt.Sprintf("  └ %v\n", s.Err))
+		}
+	}
+
+	return b.String()
+}
+
+// StatusCommand prints the health of every configured server and returns
+// the process exit code: 1 when any server is down, or none are
+// configured, so a monitoring job never mistakes a blank setup for health.
+func StatusCommand(w io.Writer) int {
+	statuses, err := checkServers(context.Background())
+	if err != nil {
+		fmt.Fprintf(w, "Error listing servers: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprint(w, formatStatusTable(statuses))
+	if len(statuses) == 0 {
+		return 1
+	}
+
+	for _, s := range statuses {
+		if !s.Up {
+			return 1
+		}
+	}
+	return 0
+}
+
+var statusModelID int64
+
+type statusResultMsg struct {
+	id       int64
+	statuses []serverStatus
+	err      error
+}
+
+type statusTickMsg struct {
+	id int64
+}
+
+type statusModel struct {
+	parent   *tea.TeaModel
+	id       int64
+	statuses []serverStatus
+	err      error
+	checking bool
+	updated  time.Time
+}
+
+func newStatusModel(parent *tea.TeaModel) bubble.Model {
+	return &statusModel{
+		parent:   parent,
+		id:       atomic.AddInt64(&statusModelID, 1),
+		checking: true,
+	}
+}
+
+func (m *statusModel) Init() bubble.Cmd {
+	return m.check()
+}
+
+func (m *statusModel) check() bubble.Cmd {
+	id := m.id
+	return func() bubble.Msg {
+		statuses, err := checkServers(context.Background())
+		return statusResultMsg{id: id, statuses: statuses, err: err}
+	}
+}
+
+func (m *statusModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
+	switch msg := msg.(type) {
+	case statusResultMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+		m.statuses, m.err = msg.statuses, msg.err
+		m.checking = false
+		m.updated = time.Now()
+
+		id := m.id
+		return m, bubble.Tick(refreshInterval, func(time.Time) bubble.Msg {
+			return statusTickMsg{id: id}
+		})
+	case statusTickMsg:
+		if msg.id != m.id || m.checking {
+			return m, nil
+		}
+		m.checking = true
+		return m, m.check()
+	case bubble.KeyMsg:
+		keys := tea.Keys()
+		switch {
+		case key.Matches(msg, keys.Refresh):
+			if !m.checking {
+				m.checking = true
+				return m, m.check()
+			}
+		case key.Matches(msg, keys.Back):
+			// invalidate any in-flight tick so the loop stops
+			m.id = -1
+			return m.parent, nil
+		case key.Matches(msg, keys.Quit):
+			m.id = -1
+			return m.parent.Quit(m.parent)
+		case key.Matches(msg, keys.ForceQuit):
+			return m, bubble.Quit
+		}
+	}
+
+	return m, nil
+}
+
+func (m *statusModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.parent.Root().BannerView())
+	b.WriteString(m.parent.TitleStyle.Render("Server Status"))
+	b.WriteString("\n\n")
+
+	switch {
+	case m.err != nil:
+		b.WriteString(fmt.Sprintf("Error: %v\n", m.err))
+	case m.statuses == nil && m.checking:
+		b.WriteString("Checking servers...\n")
+	default:
+		b.WriteString(formatStatusTable(m.statuses))
+	}
+
+	b.WriteString("\n")
+	if m.checking {
+		b.WriteString("Refreshing...")
+	} else if !m.updated.IsZero() {
+		b.WriteString(fmt.Sprintf("Updated %s, refreshing every %s", m.updated.Format("15:04:05"), refreshInterval))
+	}
+
+	keys := tea.Keys()
+	b.WriteString("\n\n")
+	b.WriteString(tea.HelpView([]key.Binding{keys.Refresh, keys.Back, tea.QuitBinding(true), keys.ForceQuit}, false))
+	b.WriteString("\n")
+
+	return b.String()
+}
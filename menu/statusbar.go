@@ -0,0 +1,126 @@
+package menu
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertgouveia/do-my-job/storage"
+	"github.com/robertgouveia/do-my-job/tea"
+)
+
+type connectionState struct {
+	Up        bool
+	CheckedAt time.Time
+}
+
+// connections remembers the outcome of the last connection made to each
+// server during this run. Nothing is persisted; the status bar only claims
+// what it has seen.
+var (
+	connectionsMu sync.Mutex
+	connections   = make(map[string]connectionState)
+)
+
+func recordConnection(serverName string, up bool) {
+	connectionsMu.Lock()
+	defer connectionsMu.Unlock()
+
+	connections[serverName] = connectionState{Up: up, CheckedAt: time.Now()}
+}
+
+func connectionSegment(serverName string) tea.StatusSegment {
+	connectionsMu.Lock()
+	state, ok := connections[serverName]
+	connectionsMu.Unlock()
+
+	switch {
+	case !ok:
+		return tea.StatusSegment{Label: "Connection", Value: "not checked"}
+	case state.Up:
+		return tea.StatusSegment{Label: "Connection", Value: "ok at " + state.CheckedAt.Format("15:04:05")}
+	default:
+		return tea.StatusSegment{Label: "Connection", Value: "failed at " + state.CheckedAt.Format("15:04:05"), Warn: true}
+	}
+}
+
+type savedConfig struct {
+	config storage.ServerConfig
+	err    error
+}
+
+// savedConfigs caches the server configs shown in the status bar and menu
+// warnings, which are drawn on every render. Actions still read configs
+// from disk, so they never run against a stale one.
+var (
+	savedConfigsMu sync.Mutex
+	savedConfigs   = make(map[string]savedConfig)
+)
+
+func cachedServerConfig(serverName string) (storage.ServerConfig, error) {
+	savedConfigsMu.Lock()
+	defer savedConfigsMu.Unlock()
+
+	saved, ok := savedConfigs[serverName]
+	if !ok {
+		saved.config, saved.err = storage.LoadServerConfig(serverName)
+		savedConfigs[serverName] = saved
+	}
+	return saved.config, saved.err
+}
+
+// forgetServerConfigs drops the cache, when a config is changed from the
+// menus or a menu showing them is opened.
+func forgetServerConfigs() {
+	savedConfigsMu.Lock()
+	defer savedConfigsMu.Unlock()
+
+	clear(savedConfigs)
+}
+
+// serverSegment describes serverName and whether its saved config is
+// complete enough to connect with.
+func serverSegment(serverName string) tea.StatusSegment {
+	config, err := cachedServerConfig(serverName)
+	if err != nil || config.IsZero() {
+		return tea.StatusSegment{Label: "Server", Value: serverName + " (not configured)", Warn: true}
+	}
+	if missing := config.Missing(); len(missing) > 0 {
+		return tea.StatusSegment{
+			Label: "Server",
+			Value: fmt.Sprintf("%s (missing %s)", serverName, strings.Join(missing, ", ")),
+			Warn:  true,
+		}
+	}
+	return tea.StatusSegment{Label: "Server", Value: fmt.Sprintf("%s [%s, %s]", serverName, strings.ToUpper(config.Env()), config.DialectName())}
+}
+
+func currentOperator() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// StatusBar sets the segments shown on every screen. Passwords are kept in
+// the server config files, so there is no vault to lock yet and the bar
+// says so rather than implying one.
+func StatusBar(mainMenu *tea.TeaModel) {
+	operator := currentOperator()
+
+	mainMenu.Status = func() []tea.StatusSegment {
+		return []tea.StatusSegment{
+			{Label: "Env", Value: strings.ToUpper(storage.CurrentEnvironment())},
+			{Label: "Vault", Value: "none"},
+			{Label: "Operator", Value: operator},
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package menu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/robertgouveia/do-my-job/storage"
+	"github.com/robertgouveia/do-my-job/tea"
+)
+
+const themeFile = "theme.json"
+
+// loadUserFile reads a JSON file the user writes by hand from the config
+// dir. These files are not kept in storage, which would stamp them with a
+// schema version and back them up on migration, or not read them at all
+// with the bolt backend.
+func loadUserFile(name string, target interface{}) error {
+	data, err := os.ReadFile(filepath.Join(storage.GetConfigDir(), name))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// LoadTheme reads theme.json from the config dir. The file names a base
+// preset (dark, light, high-contrast, plain or auto) and any colours or
+// glyphs to override. NO_COLOR always wins so screen readers stay usable.
+func LoadTheme() tea.Theme {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return tea.PlainTheme
+	}
+
+	var custom tea.Theme
+	if err := loadUserFile(themeFile, &custom); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: could not load theme: %v", err)
+		}
+		return tea.AutoTheme()
+	}
+
+	base, ok := tea.Preset(custom.Base)
+	if !ok {
+		log.Printf("Warning: unknown base theme %q, using auto", custom.Base)
+		base = tea.AutoTheme()
+	}
+
+	return base.Merge(custom)
+}
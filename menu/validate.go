@@ -0,0 +1,122 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/robertgouveia/do-my-job/database"
+	"github.com/robertgouveia/do-my-job/tea"
+)
+
+// paramValues lists the values each parameter can be bound to. Typed in
+// parameters could be anything, so they are only checked for existence.
+func (s Script) paramValues() map[string][]interface{} {
+	values := make(map[string][]interface{})
+	for _, param := range s.Params {
+		if param.Name != "" {
+			values[param.Name] = nil
+		}
+	}
+
+	for _, option := range s.Select {
+		if option.Name == "" {
+			continue
+		}
+		var candidates []interface{}
+		for i, value := range option.Values {
+			switch {
+			case !option.UseIndex:
+				candidates = append(candidates, value)
+			case len(option.ValueMap) > i:
+				candidates = append(candidates, option.ValueMap[i])
+			default:
+				candidates = append(candidates, i+1)
+			}
+		}
+		values[option.Name] = candidates
+	}
+
+	return values
+}
+
+type scriptValidation struct {
+	Script   string
+	Server   string
+	Problems []string
+	Err      error
+}
+
+func validateScripts(ctx context.Context, report func(done, total int)) []scriptValidation {
+	all := scripts()
+	results := make([]scriptValidation, len(all))
+
+	for i, script := range all {
+		report(i, len(all))
+
+		sname, _, err := script.targetServer()
+		results[i] = scriptValidation{Script: script.Title, Server: sname}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		results[i].Problems, results[i].Err = database.ValidateStatement(ctx, database.Default(), sname, script.Statement, script.paramValues())
+	}
+	report(len(all), len(all))
+
+	return results
+}
+
+func formatValidation(results []scriptValidation) string {
+	glyph := tea.CurrentTheme().WarnGlyph
+
+	failed := 0
+	var b strings.Builder
+	for _, r := range results {
+		b.WriteString(fmt.Sprintf("\n%s on %s\n", r.Script, r.Server))
+		switch {
+		case r.Err != nil:
+			failed++
+			b.WriteString(fmt.Sprintf("  %s could not check: %v\n", glyph, r.Err))
+		case len(r.Problems) > 0:
+			failed++
+			for _, problem := range r.Problems {
+				b.WriteString(fmt.Sprintf("  %s %s\n", glyph, problem))
+			}
+		default:
+			b.WriteString("  OK\n")
+		}
+	}
+
+	return fmt.Sprintf("%d scripts checked, %d with problems\n", len(results), failed) + b.String()
+}
+
+func validationFailed(results []scriptValidation) bool {
+	for _, r := range results {
+		if r.Err != nil || len(r.Problems) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func validateTask(ctx context.Context, report func(tea.Progress)) tea.Result {
+	results := validateScripts(ctx, func(done, total int) {
+		report(tea.Progress{Fraction: float64(done) / float64(total), Message: fmt.Sprintf("Checked %d of %d scripts", done, total)})
+	})
+	return tea.Show(formatValidation(results))
+}
+
+// ValidateCommand checks every script against its server's schema and
+// returns the process exit code: 1 when any script has problems.
+func ValidateCommand(w io.Writer) int {
+	results := validateScripts(context.Background(), func(int, int) {})
+	fmt.Fprint(w, formatValidation(results))
+
+	if validationFailed(results) {
+		return 1
+	}
+	return 0
+}
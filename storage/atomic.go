@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	privateFileMode = 0600
+	privateDirMode  = 0700
+)
+
+var ErrModified = errors.New("file changed on disk since it was loaded")
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+type stampCache struct {
+	mu     sync.Mutex
+	stamps map[string]fileStamp
+}
+
+func newStampCache() *stampCache {
+	return &stampCache{stamps: make(map[string]fileStamp)}
+}
+
+func (c *stampCache) record(path string) {
+	if c == nil {
+		return
+	}
+
+	info, err := os.Stat(path)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		delete(c.stamps, path)
+		return
+	}
+	c.stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+func (c *stampCache) forget(path string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.stamps, path)
+}
+
+func (c *stampCache) check(path string) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	stamp, seen := c.stamps[path]
+	c.mu.Unlock()
+
+	if !seen {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s was deleted: %w", path, ErrModified)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if !info.ModTime().Equal(stamp.modTime) || info.Size() != stamp.size {
+		return fmt.Errorf("%s: %w", path, ErrModified)
+	}
+
+	return nil
+}
+
+// Writes go to a temp file in the same directory and are renamed into place
+// so a crash never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
+
+func withFileLock(path string, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, privateFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("failed to lock file: %w", err)
+	}
+	defer unlockFile(f)
+
+	return fn()
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFileStorageWritesPrivately(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config")
+	fs, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Namespace("servers").Save("a", record{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]os.FileMode{
+		dir:                                     privateDirMode,
+		filepath.Join(dir, "servers"):           privateDirMode,
+		filepath.Join(dir, "servers", "a.json"): privateFileMode,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s has mode %o, want %o", path, got, want)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "servers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temp file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestFileStorageDetectsConcurrentEdits(t *testing.T) {
+	dir := t.TempDir()
+
+	// two instances of the tool, each with its own view of the file
+	first, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Save("a", record{Name: "start"}); err != nil {
+		t.Fatal(err)
+	}
+	var r record
+	if err := second.Load("a", &r); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Save("a", record{Name: "changed by the first", Count: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Save("a", record{Name: "stale"}); !errors.Is(err, ErrModified) {
+		t.Fatalf("got %v, want ErrModified", err)
+	}
+
+	if err := second.Load("a", &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "changed by the first" {
+		t.Errorf("the stale save overwrote the file: %+v", r)
+	}
+	if err := second.Save("a", record{Name: "after reloading"}); err != nil {
+		t.Errorf("save after reloading: %v", err)
+	}
+}
+
+func TestFileStorageUpdatesUnderLock(t *testing.T) {
+	dir := t.TempDir()
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			fs, err := NewFileStorage(dir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			var r record
+			errs <- fs.Update("counter", &r, func() error {
+				r.Count++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r record
+	if err := fs.Load("counter", &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != writers {
+		t.Errorf("got count %d after %d updates, some were lost", r.Count, writers)
+	}
+}
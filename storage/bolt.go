@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const rootBucket = "root"
+
+type BoltStorage struct {
+	db      *bolt.DB
+	buckets []string
+}
+
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+
+	return &BoltStorage{
+		db:      db,
+		buckets: []string{rootBucket},
+	}, nil
+}
+
+func (bs *BoltStorage) Close() error {
+	return bs.db.Close()
+}
+
+func (bs *BoltStorage) Save(key string, data interface{}) error {
+	jsonData, err := encode(data, false)
+	if err != nil {
+		return err
+	}
+
+	err = bs.db.Update(func(tx *bolt.Tx) error {
+		b, err := bs.createBucket(tx)
+		if err != nil {
+			return err
+		}
+		if err := checkWritable(key, b.Get([]byte(key))); err != nil {
+			return err
+		}
+		return b.Put([]byte(key), jsonData)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+
+	return nil
+}
+
+func (bs *BoltStorage) Load(key string, target interface{}) error {
+	var data []byte
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		if b := bs.bucket(tx); b != nil {
+			if v := b.Get([]byte(key)); v != nil {
+				data = append([]byte(nil), v...)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+
+	if data == nil {
+		return fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+
+	if _, version, err := upgrade(bs.namespace(), key, data); err != nil {
+		return err
+	} else if version < SchemaVersion {
+		return bs.db.Update(func(tx *bolt.Tx) error {
+			b, err := bs.createBucket(tx)
+			if err != nil {
+				return fmt.Errorf("failed to open bucket: %w", err)
+			}
+			return bs.load(b, key, target)
+		})
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+
+	return nil
+}
+
+// load must be called inside a writable transaction.
+func (bs *BoltStorage) load(b *bolt.Bucket, key string, target interface{}) error {
+	data := b.Get([]byte(key))
+	if data == nil {
+		return fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+
+	upgraded, version, err := upgrade(bs.namespace(), key, data)
+	if err != nil {
+		return err
+	}
+
+	if version < SchemaVersion {
+		backups, err := b.CreateBucketIfNotExists([]byte(".backup"))
+		if err != nil {
+			return fmt.Errorf("failed to open backup bucket: %w", err)
+		}
+		if err := backups.Put([]byte(backupName(key, version)), data); err != nil {
+			return fmt.Errorf("failed to back up %s before migration: %w", key, err)
+		}
+		if err := b.Put([]byte(key), upgraded); err != nil {
+			return fmt.Errorf("failed to write migrated key: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(upgraded, target); err != nil {
+		return fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+
+	return nil
+}
+
+func (bs *BoltStorage) Exists(key string) bool {
+	found := false
+	bs.db.View(func(tx *bolt.Tx) error {
+		if b := bs.bucket(tx); b != nil {
+			found = b.Get([]byte(key)) != nil
+		}
+		return nil
+	})
+	return found
+}
+
+func (bs *BoltStorage) List() ([]string, error) {
+	var keys []string
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b := bs.bucket(tx)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			// nil values are nested namespace buckets
+			if v != nil {
+				keys = append(keys, string(k))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (bs *BoltStorage) Delete(key string) error {
+	if !bs.Exists(key) {
+		return fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		return bs.bucket(tx).Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+
+	return nil
+}
+
+func (bs *BoltStorage) Update(key string, target interface{}, fn func() error) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b, err := bs.createBucket(tx)
+		if err != nil {
+			return fmt.Errorf("failed to open bucket: %w", err)
+		}
+
+		if err := bs.load(b, key, target); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+
+		jsonData, err := encode(target, false)
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(key), jsonData)
+	})
+}
+
+func (bs *BoltStorage) Namespace(name string) Storage {
+	buckets := append(append([]string(nil), bs.buckets...), name)
+	return &BoltStorage{
+		db:      bs.db,
+		buckets: buckets,
+	}
+}
+
+func (bs *BoltStorage) namespace() string {
+	return strings.Join(bs.buckets[1:], "/")
+}
+
+func (bs *BoltStorage) Location(key string) string {
+	return fmt.Sprintf("%s#%s/%s", bs.db.Path(), bs.namespace(), key)
+}
+
+func (bs *BoltStorage) bucket(tx *bolt.Tx) *bolt.Bucket {
+	b := tx.Bucket([]byte(bs.buckets[0]))
+	for _, name := range bs.buckets[1:] {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket([]byte(name))
+	}
+	return b
+}
+
+func (bs *BoltStorage) createBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(bs.buckets[0]))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range bs.buckets[1:] {
+		b, err = b.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
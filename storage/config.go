@@ -1,39 +1,153 @@
 package storage
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
+
+	"github.com/robertgouveia/do-my-job/lib"
+)
+
+const (
+	appName           = "do-my-job"
+	serversNamespace  = "servers"
+	settingsNamespace = "settings"
+	sessionKey        = "session"
 )
 
+const (
+	EnvDev  = "dev"
+	EnvTest = "test"
+	EnvProd = "prod"
+)
+
+var Environments = []string{EnvDev, EnvTest, EnvProd}
+
+const (
+	DialectMSSQL    = "mssql"
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+	DialectSQLite   = "sqlite"
+)
+
+var Dialects = []string{DialectMSSQL, DialectPostgres, DialectMySQL, DialectSQLite}
+
 type ServerConfig struct {
 	Host        string    `json:"host"`
 	Port        string    `json:"port"`
 	Username    string    `json:"username"`
 	Database    string    `json:"database"`
 	Password    string    `json:"password"`
+	Environment string    `json:"environment"`
+	Dialect     string    `json:"dialect"`
 	LastUpdated time.Time `json:"last_updated"`
 }
 
-func SaveServerConfig(serverName string, config ServerConfig) error {
-	configDir := GetConfigDir()
-	err := os.MkdirAll(configDir, 0755)
-	if err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+// IsZero reports whether nothing has been saved for the server.
+func (c ServerConfig) IsZero() bool {
+	return c == ServerConfig{}
+}
+
+// Missing lists the fields a connection needs that have not been set.
+// SQLite only needs a file path, which is kept in Host.
+func (c ServerConfig) Missing() []string {
+	var missing []string
+	if c.Host == "" {
+		missing = append(missing, "host")
+	}
+	if c.DialectName() == DialectSQLite {
+		return missing
+	}
+	if c.Username == "" {
+		missing = append(missing, "username")
+	}
+	if c.Database == "" {
+		missing = append(missing, "database")
 	}
+	return missing
+}
 
-	filePath := filepath.Join(configDir, serverName+".json")
+// Env treats untagged servers as prod so they get the strictest handling.
+func (c ServerConfig) Env() string {
+	return lib.StringOrDefault(c.Environment, EnvProd)
+}
 
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal config to JSON: %w", err)
+// DialectName defaults to SQL Server, which every server used before
+// other databases were supported.
+func (c ServerConfig) DialectName() string {
+	return lib.StringOrDefault(c.Dialect, DialectMSSQL)
+}
+
+type Session struct {
+	Environment string `json:"environment"`
+}
+
+func init() {
+	// Servers configured before environments existed all pointed at live
+	// systems, so treat them as prod until someone says otherwise.
+	RegisterMigration(serversNamespace, 1, func(doc map[string]interface{}) error {
+		if env, _ := doc["environment"].(string); env == "" {
+			doc["environment"] = EnvProd
+		}
+		return nil
+	})
+	RegisterMigration(serversNamespace, 2, func(doc map[string]interface{}) error {
+		if dialect, _ := doc["dialect"].(string); dialect == "" {
+			doc["dialect"] = DialectMSSQL
+		}
+		return nil
+	})
+}
+
+var (
+	defaultStorage Storage
+	defaultOnce    sync.Once
+)
+
+func SetDefault(s Storage) {
+	defaultOnce.Do(func() {})
+	defaultStorage = s
+}
+
+func Default() Storage {
+	defaultOnce.Do(func() {
+		s, err := openDefault()
+		if err != nil {
+			log.Printf("Warning: falling back to file storage: %v", err)
+			s = &FileStorage{BasePath: GetConfigDir()}
+		}
+		defaultStorage = s
+	})
+	return defaultStorage
+}
+
+func openDefault() (Storage, error) {
+	switch backend := os.Getenv("DO_MY_JOB_STORAGE"); backend {
+	case "", "file":
+		return DefaultStorage(appName)
+	case "bolt":
+		if err := os.MkdirAll(GetConfigDir(), privateDirMode); err != nil {
+			return nil, fmt.Errorf("failed to create config directory: %w", err)
+		}
+		return NewBoltStorage(filepath.Join(GetConfigDir(), appName+".db"))
+	case "memory":
+		return NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
 	}
+}
 
-	err = os.WriteFile(filePath, data, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+func servers() Storage {
+	return Default().Namespace(serversNamespace)
+}
+
+func SaveServerConfig(serverName string, config ServerConfig) error {
+	if err := servers().Save(serverName, config); err != nil {
+		return fmt.Errorf("failed to save server config: %w", err)
 	}
 
 	return nil
@@ -42,29 +156,144 @@ func SaveServerConfig(serverName string, config ServerConfig) error {
 func LoadServerConfig(serverName string) (ServerConfig, error) {
 	var config ServerConfig
 
-	filePath := filepath.Join(GetConfigDir(), serverName+".json")
+	if err := servers().Load(serverName, &config); err == nil {
+		return config, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return config, fmt.Errorf("failed to load server config: %w", err)
+	}
+
+	return loadLegacyServerConfig(serverName)
+}
+
+// Configs used to live in the root of the config dir; move them into the
+// servers namespace the first time they are read.
+func loadLegacyServerConfig(serverName string) (ServerConfig, error) {
+	var config ServerConfig
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	root := Default()
+	if !root.Exists(serverName) {
 		return config, nil
 	}
 
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return config, fmt.Errorf("failed to read config file: %w", err)
+	if err := root.Load(serverName, &config); err != nil {
+		return config, fmt.Errorf("failed to load server config: %w", err)
+	}
+
+	config.Environment = lib.StringOrDefault(config.Environment, EnvProd)
+	config.Dialect = lib.StringOrDefault(config.Dialect, DialectMSSQL)
+
+	if err := servers().Save(serverName, config); err != nil {
+		return config, fmt.Errorf("failed to migrate server config: %w", err)
+	}
+
+	if err := root.Delete(serverName); err != nil {
+		log.Printf("Warning: could not remove legacy config for %s: %v", serverName, err)
 	}
 
-	err = json.Unmarshal(data, &config)
+	return config, nil
+}
+
+// sameAs compares two configs, matching LastUpdated by instant since it
+// loses its monotonic reading and maybe its zone on the way through JSON.
+func (c ServerConfig) sameAs(other ServerConfig) bool {
+	if !c.LastUpdated.Equal(other.LastUpdated) {
+		return false
+	}
+	c.LastUpdated, other.LastUpdated = time.Time{}, time.Time{}
+	return c == other
+}
+
+func UpdateServerConfig(serverName string, fn func(*ServerConfig)) (ServerConfig, error) {
+	return updateServerConfig(serverName, nil, fn)
+}
+
+// EditServerConfig is UpdateServerConfig for changes made on top of base,
+// the config as it was when e.g. a form was opened. It fails with
+// ErrModified rather than overwrite anything saved since then.
+func EditServerConfig(serverName string, base ServerConfig, fn func(*ServerConfig)) (ServerConfig, error) {
+	return updateServerConfig(serverName, &base, fn)
+}
+
+func updateServerConfig(serverName string, base *ServerConfig, fn func(*ServerConfig)) (ServerConfig, error) {
+	if _, err := LoadServerConfig(serverName); err != nil {
+		return ServerConfig{}, err
+	}
+
+	var config ServerConfig
+	err := servers().Update(serverName, &config, func() error {
+		if base != nil && !config.sameAs(*base) {
+			return fmt.Errorf("%s was changed elsewhere: %w", serverName, ErrModified)
+		}
+		fn(&config)
+		config.LastUpdated = time.Now()
+		return nil
+	})
 	if err != nil {
-		return config, fmt.Errorf("failed to parse config JSON: %w", err)
+		return config, fmt.Errorf("failed to update server config: %w", err)
 	}
 
 	return config, nil
 }
 
+func DeleteServerConfig(serverName string) error {
+	return servers().Delete(serverName)
+}
+
+func ListServerConfigs() ([]string, error) {
+	return servers().List()
+}
+
+func ServerConfigLocation(serverName string) string {
+	return servers().Location(serverName)
+}
+
+func IsEnvironment(env string) bool {
+	for _, e := range Environments {
+		if e == env {
+			return true
+		}
+	}
+	return false
+}
+
+func IsDialect(dialect string) bool {
+	for _, d := range Dialects {
+		if d == dialect {
+			return true
+		}
+	}
+	return false
+}
+
+func CurrentEnvironment() string {
+	var session Session
+	if err := Default().Namespace(settingsNamespace).Load(sessionKey, &session); err != nil {
+		return EnvProd
+	}
+	return lib.StringOrDefault(session.Environment, EnvProd)
+}
+
+func SetCurrentEnvironment(env string) error {
+	if !IsEnvironment(env) {
+		return fmt.Errorf("unknown environment: %s", env)
+	}
+
+	var session Session
+	err := Default().Namespace(settingsNamespace).Update(sessionKey, &session, func() error {
+		session.Environment = env
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	return nil
+}
+
 func GetConfigDir() string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return ".config/do-my-job"
+		return ".config/" + appName
 	}
-	return filepath.Join(homeDir, ".config", "do-my-job")
+	return filepath.Join(homeDir, ".config", appName)
 }
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerConfigMigratesToDialect(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetDefault(fs)
+
+	serversDir := filepath.Join(dir, serversNamespace)
+	if err := os.MkdirAll(serversDir, privateDirMode); err != nil {
+		t.Fatal(err)
+	}
+	v1 := `{"host": "db.local", "username": "sa", "database": "NAV"}`
+	if err := os.WriteFile(filepath.Join(serversDir, "old.json"), []byte(v1), privateFileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadServerConfig("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Environment != EnvProd || config.Dialect != DialectMSSQL {
+		t.Errorf("got environment %q, dialect %q", config.Environment, config.Dialect)
+	}
+
+	if _, err := os.Stat(filepath.Join(serversDir, backupName("old", 0))); err != nil {
+		t.Errorf("expected a backup of the original file: %v", err)
+	}
+}
+
+func TestMissingDependsOnDialect(t *testing.T) {
+	if got := (ServerConfig{Host: "a.db", Dialect: DialectSQLite}).Missing(); len(got) != 0 {
+		t.Errorf("sqlite only needs a path, got missing %v", got)
+	}
+	if got := (ServerConfig{Host: "db"}).Missing(); len(got) != 2 {
+		t.Errorf("SQL Server needs a username and database, got missing %v", got)
+	}
+}
+
+func TestEditServerConfigRefusesStaleChanges(t *testing.T) {
+	SetDefault(NewMemoryStorage())
+
+	if err := SaveServerConfig("db", ServerConfig{Host: "db.local", Username: "sa"}); err != nil {
+		t.Fatal(err)
+	}
+	opened, err := LoadServerConfig("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another instance saves while the form is open
+	if _, err := UpdateServerConfig("db", func(c *ServerConfig) { c.Password = "rotated" }); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = EditServerConfig("db", opened, func(c *ServerConfig) { c.Host = "db2.local" })
+	if !errors.Is(err, ErrModified) {
+		t.Fatalf("got %v, want ErrModified", err)
+	}
+	if config, _ := LoadServerConfig("db"); config.Host != "db.local" || config.Password != "rotated" {
+		t.Errorf("the stale edit was written: %+v", config)
+	}
+
+	latest, _ := LoadServerConfig("db")
+	if _, err := EditServerConfig("db", latest, func(c *ServerConfig) { c.Host = "db2.local" }); err != nil {
+		t.Errorf("edit of the latest config: %v", err)
+	}
+}
@@ -2,66 +2,223 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
+var ErrNotFound = errors.New("key not found")
+
 type Storage interface {
 	Save(key string, data interface{}) error
 	Load(key string, target interface{}) error
 	Exists(key string) bool
+	List() ([]string, error)
+	Delete(key string) error
+	Update(key string, target interface{}, fn func() error) error
+	Namespace(name string) Storage
+	Location(key string) string
 }
 
 type FileStorage struct {
 	BasePath string
+
+	namespace string
+	stamps    *stampCache
 }
 
 func NewFileStorage(basePath string) (*FileStorage, error) {
-	if err := os.MkdirAll(basePath, 0755); err != nil {
+	if err := os.MkdirAll(basePath, privateDirMode); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
 	return &FileStorage{
 		BasePath: basePath,
+		stamps:   newStampCache(),
 	}, nil
 }
 
 func (fs *FileStorage) Save(key string, data interface{}) error {
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	jsonData, err := encode(data, true)
 	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
+		return err
 	}
 
-	filePath := filepath.Join(fs.BasePath, key+".json")
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	path := fs.Location(key)
+	return withFileLock(path, func() error {
+		if err := fs.stamps.check(path); err != nil {
+			return err
+		}
+
+		existing, err := fs.read(key)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err := checkWritable(key, existing); err != nil {
+			return err
+		}
+
+		if err := writeFileAtomic(path, jsonData, privateFileMode); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+
+		fs.stamps.record(path)
+		return nil
+	})
+}
+
+// Writes are atomic renames, so reads never see a partial file and only
+// need the lock when an older document has to be migrated in place.
+func (fs *FileStorage) Load(key string, target interface{}) error {
+	data, err := fs.read(key)
+	if err != nil {
+		return err
+	}
+
+	if _, version, err := upgrade(fs.namespace, key, data); err != nil {
+		return err
+	} else if version < SchemaVersion {
+		return withFileLock(fs.Location(key), func() error {
+			return fs.load(key, target)
+		})
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to unmarshal data: %w", err)
 	}
 
+	fs.stamps.record(fs.Location(key))
 	return nil
 }
 
-func (fs *FileStorage) Load(key string, target interface{}) error {
-	filePath := filepath.Join(fs.BasePath, key+".json")
+func (fs *FileStorage) read(key string) ([]byte, error) {
+	data, err := os.ReadFile(fs.Location(key))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
 
-	data, err := os.ReadFile(filePath)
+	return data, nil
+}
+
+// load must be called with the file lock held.
+func (fs *FileStorage) load(key string, target interface{}) error {
+	path := fs.Location(key)
+
+	data, err := fs.read(key)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return err
 	}
 
-	if err := json.Unmarshal(data, target); err != nil {
+	upgraded, version, err := upgrade(fs.namespace, key, data)
+	if err != nil {
+		return err
+	}
+
+	if version < SchemaVersion {
+		backup := filepath.Join(fs.BasePath, backupName(key, version))
+		if err := writeFileAtomic(backup, data, privateFileMode); err != nil {
+			return fmt.Errorf("failed to back up %s before migration: %w", key, err)
+		}
+
+		if err := writeFileAtomic(path, upgraded, privateFileMode); err != nil {
+			return fmt.Errorf("failed to write migrated file: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(upgraded, target); err != nil {
 		return fmt.Errorf("failed to unmarshal data: %w", err)
 	}
 
+	fs.stamps.record(path)
 	return nil
 }
 
+// Update holds the file lock across the read, fn and write so that two
+// instances editing the same key cannot lose each other's changes.
+func (fs *FileStorage) Update(key string, target interface{}, fn func() error) error {
+	path := fs.Location(key)
+	return withFileLock(path, func() error {
+		if err := fs.load(key, target); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+
+		jsonData, err := encode(target, true)
+		if err != nil {
+			return err
+		}
+
+		if err := writeFileAtomic(path, jsonData, privateFileMode); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+
+		fs.stamps.record(path)
+		return nil
+	})
+}
+
 func (fs *FileStorage) Exists(key string) bool {
-	filePath := filepath.Join(fs.BasePath, key+".json")
-	_, err := os.Stat(filePath)
+	_, err := os.Stat(fs.Location(key))
 	return err == nil
 }
 
+func (fs *FileStorage) List() ([]string, error) {
+	entries, err := os.ReadDir(fs.BasePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		keys = append(keys, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+
+	return keys, nil
+}
+
+func (fs *FileStorage) Delete(key string) error {
+	path := fs.Location(key)
+	return withFileLock(path, func() error {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+
+		fs.stamps.forget(path)
+		return nil
+	})
+}
+
+func (fs *FileStorage) Namespace(name string) Storage {
+	return &FileStorage{
+		BasePath:  filepath.Join(fs.BasePath, name),
+		namespace: path.Join(fs.namespace, name),
+		stamps:    fs.stamps,
+	}
+}
+
+func (fs *FileStorage) Location(key string) string {
+	return filepath.Join(fs.BasePath, key+".json")
+}
+
 func DefaultStorage(appName string) (*FileStorage, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
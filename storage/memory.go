@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type MemoryStorage struct {
+	mu     *sync.RWMutex
+	data   map[string][]byte
+	prefix string
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		mu:   &sync.RWMutex{},
+		data: make(map[string][]byte),
+	}
+}
+
+func (ms *MemoryStorage) Save(key string, data interface{}) error {
+	jsonData, err := encode(data, false)
+	if err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := checkWritable(key, ms.data[ms.prefix+key]); err != nil {
+		return err
+	}
+
+	ms.data[ms.prefix+key] = jsonData
+	return nil
+}
+
+func (ms *MemoryStorage) Load(key string, target interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.load(key, target)
+}
+
+func (ms *MemoryStorage) load(key string, target interface{}) error {
+	data, ok := ms.data[ms.prefix+key]
+	if !ok {
+		return fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+
+	upgraded, version, err := upgrade(ms.namespace(), key, data)
+	if err != nil {
+		return err
+	}
+
+	if version < SchemaVersion {
+		ms.data[ms.prefix+".backup/"+backupName(key, version)] = data
+		ms.data[ms.prefix+key] = upgraded
+	}
+
+	if err := json.Unmarshal(upgraded, target); err != nil {
+		return fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+
+	return nil
+}
+
+func (ms *MemoryStorage) Exists(key string) bool {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	_, ok := ms.data[ms.prefix+key]
+	return ok
+}
+
+func (ms *MemoryStorage) List() ([]string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var keys []string
+	for k := range ms.data {
+		if !strings.HasPrefix(k, ms.prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(k, ms.prefix)
+		if strings.Contains(key, "/") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (ms *MemoryStorage) Delete(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.data[ms.prefix+key]; !ok {
+		return fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	delete(ms.data, ms.prefix+key)
+
+	return nil
+}
+
+func (ms *MemoryStorage) Update(key string, target interface{}, fn func() error) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ms.load(key, target); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	jsonData, err := encode(target, false)
+	if err != nil {
+		return err
+	}
+
+	ms.data[ms.prefix+key] = jsonData
+	return nil
+}
+
+func (ms *MemoryStorage) Namespace(name string) Storage {
+	return &MemoryStorage{
+		mu:     ms.mu,
+		data:   ms.data,
+		prefix: ms.prefix + name + "/",
+	}
+}
+
+func (ms *MemoryStorage) namespace() string {
+	return strings.TrimSuffix(ms.prefix, "/")
+}
+
+func (ms *MemoryStorage) Location(key string) string {
+	return "memory://" + ms.prefix + key
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	SchemaVersion = 3
+	schemaField   = "schema_version"
+)
+
+var ErrNewerSchema = errors.New("stored document is newer than this build supports")
+
+// A Migration upgrades a decoded document from one schema version to the
+// next. It is keyed by namespace and the version it upgrades from.
+type Migration func(doc map[string]interface{}) error
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = make(map[string]map[int]Migration)
+)
+
+func RegisterMigration(namespace string, from int, fn Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	if migrations[namespace] == nil {
+		migrations[namespace] = make(map[int]Migration)
+	}
+	migrations[namespace][from] = fn
+}
+
+func encode(data interface{}, indent bool) ([]byte, error) {
+	var raw []byte
+	var err error
+	if indent {
+		raw, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		raw, err = json.Marshal(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if json.Unmarshal(raw, &doc) != nil {
+		// only JSON objects carry a version stamp
+		return raw, nil
+	}
+
+	doc[schemaField] = SchemaVersion
+
+	if indent {
+		raw, err = json.MarshalIndent(doc, "", "  ")
+	} else {
+		raw, err = json.Marshal(doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	return raw, nil
+}
+
+// upgrade returns the document migrated to SchemaVersion along with the
+// version it was stored at. Documents without a stamp are version 0.
+func upgrade(namespace, key string, raw []byte) ([]byte, int, error) {
+	var doc map[string]interface{}
+	if json.Unmarshal(raw, &doc) != nil {
+		return raw, SchemaVersion, nil
+	}
+
+	version, err := docVersion(key, doc)
+	if err != nil {
+		return nil, version, err
+	}
+
+	if version == SchemaVersion {
+		return raw, version, nil
+	}
+
+	migrationsMu.RLock()
+	steps := migrations[namespace]
+	migrationsMu.RUnlock()
+
+	for v := version; v < SchemaVersion; v++ {
+		if fn, ok := steps[v]; ok {
+			if err := fn(doc); err != nil {
+				return nil, version, fmt.Errorf("failed to migrate %s from schema v%d: %w", key, v, err)
+			}
+		}
+	}
+
+	doc[schemaField] = SchemaVersion
+
+	upgraded, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, version, fmt.Errorf("failed to marshal migrated data: %w", err)
+	}
+
+	return upgraded, version, nil
+}
+
+// checkWritable refuses to replace a stored document written by a newer
+// version of the tool, as saving over it would drop the fields this build
+// does not know about.
+func checkWritable(key string, raw []byte) error {
+	var doc map[string]interface{}
+	if raw == nil || json.Unmarshal(raw, &doc) != nil {
+		return nil
+	}
+	_, err := docVersion(key, doc)
+	return err
+}
+
+func docVersion(key string, doc map[string]interface{}) (int, error) {
+	version := 0
+	if v, ok := doc[schemaField].(float64); ok {
+		version = int(v)
+	}
+
+	if version > SchemaVersion {
+		return version, fmt.Errorf(
+			"%s was written by a newer version of this tool (schema v%d, this build supports v%d); update the tool before using it: %w",
+			key, version, SchemaVersion, ErrNewerSchema,
+		)
+	}
+	return version, nil
+}
+
+func backupName(key string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", key, version)
+}
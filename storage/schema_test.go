@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// writeRaw stores data under key as is, bypassing the version stamp.
+func writeRaw(t *testing.T, s Storage, key, data string) {
+	t.Helper()
+
+	var err error
+	switch s := s.(type) {
+	case *FileStorage:
+		err = os.WriteFile(s.Location(key), []byte(data), privateFileMode)
+	case *BoltStorage:
+		err = s.db.Update(func(tx *bolt.Tx) error {
+			b, err := s.createBucket(tx)
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte(key), []byte(data))
+		})
+	case *MemoryStorage:
+		s.data[s.prefix+key] = []byte(data)
+	default:
+		t.Fatalf("unknown backend %T", s)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackendsMigrateOlderDocuments(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			servers := s.Namespace(serversNamespace)
+			if fs, ok := servers.(*FileStorage); ok {
+				if err := os.MkdirAll(fs.BasePath, privateDirMode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeRaw(t, servers, "old", `{"host": "db.local"}`)
+
+			var config ServerConfig
+			if err := servers.Load("old", &config); err != nil {
+				t.Fatal(err)
+			}
+			if config.Environment != EnvProd || config.Dialect != DialectMSSQL {
+				t.Errorf("got environment %q, dialect %q", config.Environment, config.Dialect)
+			}
+		})
+	}
+}
+
+func TestBackendsRefuseNewerSchema(t *testing.T) {
+	newer := fmt.Sprintf(`{"name": "future", "%s": %d}`, schemaField, SchemaVersion+1)
+
+	tests := []struct {
+		name string
+		op   func(s Storage) error
+	}{
+		{"load", func(s Storage) error {
+			var r record
+			return s.Load("doc", &r)
+		}},
+		{"update", func(s Storage) error {
+			var r record
+			return s.Update("doc", &r, func() error { return nil })
+		}},
+		{"save", func(s Storage) error {
+			return s.Save("doc", record{Name: "older"})
+		}},
+	}
+
+	for name, s := range backends(t) {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				writeRaw(t, s, "doc", newer)
+
+				if err := tt.op(s); !errors.Is(err, ErrNewerSchema) {
+					t.Fatalf("got %v, want ErrNewerSchema", err)
+				}
+
+				var r record
+				if err := s.Save("other", record{}); err != nil {
+					t.Errorf("other keys should still save: %v", err)
+				}
+				if err := s.Load("doc", &r); !errors.Is(err, ErrNewerSchema) {
+					t.Errorf("the newer document was overwritten: %+v, %v", r, err)
+				}
+			})
+		}
+	}
+}
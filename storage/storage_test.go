@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type record struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func backends(t *testing.T) map[string]Storage {
+	t.Helper()
+
+	fs, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := NewBoltStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bs.Close() })
+
+	return map[string]Storage{
+		"file":   fs,
+		"bolt":   bs,
+		"memory": NewMemoryStorage(),
+	}
+}
+
+func TestBackendsRoundTrip(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			var missing record
+			if err := s.Load("absent", &missing); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Load of a missing key: got %v, want ErrNotFound", err)
+			}
+
+			if err := s.Save("a", record{Name: "first", Count: 1}); err != nil {
+				t.Fatal(err)
+			}
+			var got record
+			if err := s.Load("a", &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != (record{Name: "first", Count: 1}) {
+				t.Errorf("got %+v after a save", got)
+			}
+
+			err := s.Update("a", &got, func() error {
+				got.Count++
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = record{}
+			if err := s.Load("a", &got); err != nil || got.Count != 2 {
+				t.Errorf("got %+v, %v after an update", got, err)
+			}
+
+			servers := s.Namespace("servers")
+			if err := servers.Save("b", record{Name: "nested"}); err != nil {
+				t.Fatal(err)
+			}
+			if keys, err := s.List(); err != nil || strings.Join(keys, ",") != "a" {
+				t.Errorf("List of the root: got %v, %v", keys, err)
+			}
+			if keys, err := servers.List(); err != nil || strings.Join(keys, ",") != "b" {
+				t.Errorf("List of the namespace: got %v, %v", keys, err)
+			}
+			if s.Exists("b") || !servers.Exists("b") {
+				t.Error("namespaced keys should only exist in their namespace")
+			}
+
+			if err := s.Delete("a"); err != nil {
+				t.Fatal(err)
+			}
+			if s.Exists("a") {
+				t.Error("key still exists after Delete")
+			}
+			if err := s.Delete("a"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("second Delete: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package tea
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	bubble "github.com/charmbracelet/bubbletea"
+)
+
+type ConfirmModel struct {
+	Parent    *TeaModel
+	TextInput textinput.Model
+	Title     string
+	Phrase    string
+	Item      MenuItem
+	Mismatch  bool
+}
+
+func NewConfirmModel(parent *TeaModel, phrase string, item MenuItem) *ConfirmModel {
+	ti := textinput.New()
+	ti.Placeholder = phrase
+	ti.Focus()
+	ti.CharLimit = 156
+	ti.Width = 50
+
+	return &ConfirmModel{
+		Parent:    parent,
+		TextInput: ti,
+		Title:     item.Title,
+		Phrase:    phrase,
+		Item:      item,
+	}
+}
+
+func (m ConfirmModel) Init() bubble.Cmd {
+	return textinput.Blink
+}
+
+func (m *ConfirmModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
+	var cmd bubble.Cmd
+
+	switch msg := msg.(type) {
+	case bubble.KeyMsg:
+		switch {
+		case key.Matches(msg, inputKeys.Select):
+			if strings.TrimSpace(m.TextInput.Value()) != m.Phrase {
+				m.Mismatch = true
+				m.TextInput.Reset()
+				return m, nil
+			}
+			return m.Parent.run(m.Item)
+		case key.Matches(msg, inputKeys.Back):
+			return m.Parent, nil
+		case key.Matches(msg, inputKeys.ForceQuit):
+			return m, bubble.Quit
+		}
+	}
+
+	m.TextInput, cmd = m.TextInput.Update(msg)
+	return m, cmd
+}
+
+func (m ConfirmModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.Parent.Root().BannerView())
+	b.WriteString(fmt.Sprintf("%s\n\n", m.Title))
+	b.WriteString(fmt.Sprintf("This action needs confirmation. Type %q to continue:\n\n", m.Phrase))
+	b.WriteString(m.TextInput.View())
+
+	if m.Mismatch {
+		b.WriteString("\n\n")
+		b.WriteString(m.Parent.ErrorStyle.Render("Confirmation did not match."))
+	}
+
+	b.WriteString("\n\n")
+	b.WriteString(HelpView([]key.Binding{inputBinding(inputKeys.Select, "confirm"), inputBinding(inputKeys.Back, "cancel")}, false))
+
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package tea
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	bubble "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type ErrorModel struct {
+	Parent *TeaModel
+	Title  string
+	Err    error
+	Retry  func() Result
+
+	// RetryTask reruns a failed background task instead of Retry.
+	RetryTask Task
+	// Confirm guards the retry the same way it guarded the first run.
+	Confirm func() string
+}
+
+func NewErrorModel(parent *TeaModel, title string, err error, retry func() Result) *ErrorModel {
+	return &ErrorModel{
+		Parent: parent,
+		Title:  title,
+		Err:    err,
+		Retry:  retry,
+	}
+}
+
+func (m ErrorModel) Init() bubble.Cmd {
+	return nil
+}
+
+func (m *ErrorModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
+	switch msg := msg.(type) {
+	case bubble.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Retry):
+			if m.RetryTask != nil || m.Retry != nil {
+				return m.Parent.start(MenuItem{Title: m.Title, Task: m.RetryTask, Content: m.Retry, Confirm: m.Confirm})
+			}
+		case key.Matches(msg, keys.ForceQuit):
+			return m, bubble.Quit
+		case key.Matches(msg, keys.Back, keys.Select):
+			return m.Parent, nil
+		case key.Matches(msg, keys.Quit):
+			return m.Parent.Quit(m.Parent)
+		}
+	}
+
+	return m, nil
+}
+
+func (m ErrorModel) View() string {
+	var b strings.Builder
+
+	width, _ := m.Parent.Root().size()
+
+	b.WriteString(m.Parent.Root().BannerView())
+	b.WriteString(fmt.Sprintf("%s\n\n", m.Parent.TitleStyle.Render(m.Title)))
+	b.WriteString(m.Parent.ErrorStyle.Render("Error"))
+	b.WriteString("\n\n")
+	b.WriteString(lipgloss.NewStyle().Width(width).Render(m.Err.Error()))
+	b.WriteString("\n\n")
+
+	retry := keys.Retry
+	retry.SetEnabled(m.Retry != nil || m.RetryTask != nil)
+	b.WriteString(HelpView([]key.Binding{retry, keys.Back, QuitBinding(true), keys.ForceQuit}, false))
+	b.WriteString("\n")
+
+	return b.String()
+}
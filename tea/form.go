@@ -0,0 +1,229 @@
+package tea
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	bubble "github.com/charmbracelet/bubbletea"
+)
+
+type FormField struct {
+	Label       string
+	Value       string
+	Placeholder string
+	Masked      bool
+	Validate    func(string) error
+}
+
+// Required is a FormField validator that rejects blank values.
+func Required(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("required")
+	}
+	return nil
+}
+
+type formKeyMap struct {
+	next, previous, press, submit, cancel, forceQuit key.Binding
+}
+
+// formKeys moves between fields with tab as well as the keymap's up and
+// down, which the fields' text inputs leave alone.
+func formKeys() formKeyMap {
+	return formKeyMap{
+		next:      inputBinding(inputKeys.Down, "next", "tab"),
+		previous:  inputBinding(inputKeys.Up, "previous", "shift+tab"),
+		press:     inputBinding(inputKeys.Select, "next/press"),
+		submit:    inputBinding(inputKeys.Submit, "submit"),
+		cancel:    inputBinding(inputKeys.Back, "cancel"),
+		forceQuit: inputKeys.ForceQuit,
+	}
+}
+
+type FormModel struct {
+	Parent   *TeaModel
+	Title    string
+	Fields   []FormField
+	Inputs   []textinput.Model
+	Errors   []string
+	Focus    int
+	OnSubmit func(values []string) Result
+}
+
+func NewFormModel(parent *TeaModel, title string, fields []FormField, onSubmit func(values []string) Result) *FormModel {
+	inputs := make([]textinput.Model, len(fields))
+	for i, field := range fields {
+		ti := textinput.New()
+		ti.Prompt = ""
+		ti.Placeholder = field.Placeholder
+		ti.CharLimit = 156
+		ti.Width = 40
+		ti.SetValue(field.Value)
+		if field.Masked {
+			ti.EchoMode = textinput.EchoPassword
+			ti.EchoCharacter = '•'
+		}
+		inputs[i] = ti
+	}
+
+	m := &FormModel{
+		Parent:   parent,
+		Title:    title,
+		Fields:   fields,
+		Inputs:   inputs,
+		Errors:   make([]string, len(fields)),
+		OnSubmit: onSubmit,
+	}
+	if len(m.Inputs) > 0 {
+		m.Inputs[0].Focus()
+	}
+
+	return m
+}
+
+func (m FormModel) Init() bubble.Cmd {
+	return textinput.Blink
+}
+
+// Focus positions past the last field are the Submit and Cancel buttons.
+func (m *FormModel) submitIndex() int {
+	return len(m.Inputs)
+}
+
+func (m *FormModel) cancelIndex() int {
+	return len(m.Inputs) + 1
+}
+
+func (m *FormModel) setFocus(focus int) {
+	if m.Focus < len(m.Inputs) {
+		m.validate(m.Focus)
+		m.Inputs[m.Focus].Blur()
+	}
+
+	m.Focus = (focus + m.cancelIndex() + 1) % (m.cancelIndex() + 1)
+
+	if m.Focus < len(m.Inputs) {
+		m.Inputs[m.Focus].Focus()
+	}
+}
+
+func (m *FormModel) validate(i int) bool {
+	m.Errors[i] = ""
+	if m.Fields[i].Validate == nil {
+		return true
+	}
+
+	if err := m.Fields[i].Validate(m.Inputs[i].Value()); err != nil {
+		m.Errors[i] = err.Error()
+		return false
+	}
+	return true
+}
+
+func (m *FormModel) submit() (bubble.Model, bubble.Cmd) {
+	valid := true
+	for i := range m.Inputs {
+		if !m.validate(i) {
+			valid = false
+		}
+	}
+
+	if !valid {
+		for i, e := range m.Errors {
+			if e != "" {
+				m.setFocus(i)
+				break
+			}
+		}
+		return m, nil
+	}
+
+	values := make([]string, len(m.Inputs))
+	for i, input := range m.Inputs {
+		values[i] = input.Value()
+	}
+
+	return m.Parent.finish(m.Title, m.OnSubmit(values))
+}
+
+func (m *FormModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
+	var cmd bubble.Cmd
+
+	switch msg := msg.(type) {
+	case bubble.KeyMsg:
+		keys := formKeys()
+		switch {
+		case key.Matches(msg, keys.next):
+			m.setFocus(m.Focus + 1)
+			return m, nil
+		case key.Matches(msg, keys.previous):
+			m.setFocus(m.Focus - 1)
+			return m, nil
+		case key.Matches(msg, keys.submit):
+			return m.submit()
+		case key.Matches(msg, keys.cancel):
+			return m.Parent, nil
+		case key.Matches(msg, keys.forceQuit):
+			return m, bubble.Quit
+		case key.Matches(msg, keys.press):
+			switch m.Focus {
+			case m.submitIndex():
+				return m.submit()
+			case m.cancelIndex():
+				return m.Parent, nil
+			default:
+				m.setFocus(m.Focus + 1)
+				return m, nil
+			}
+		}
+	}
+
+	if m.Focus < len(m.Inputs) {
+		m.Inputs[m.Focus], cmd = m.Inputs[m.Focus].Update(msg)
+	}
+	return m, cmd
+}
+
+func (m FormModel) View() string {
+	var b strings.Builder
+
+	labelWidth := 0
+	for _, field := range m.Fields {
+		labelWidth = max(labelWidth, len(field.Label))
+	}
+
+	b.WriteString(m.Parent.Root().BannerView())
+	b.WriteString(fmt.Sprintf("%s\n\n", m.Parent.TitleStyle.Render(m.Title)))
+
+	for i, field := range m.Fields {
+		cursor := " "
+		if m.Focus == i {
+			cursor = m.Parent.CursorStyle.Render(currentTheme.CursorGlyph)
+		}
+
+		b.WriteString(fmt.Sprintf("%s %-*s %s\n", cursor, labelWidth, field.Label, m.Inputs[i].View()))
+		if m.Errors[i] != "" {
+			b.WriteString(fmt.Sprintf("  %*s %s\n", labelWidth, "", m.Parent.ErrorStyle.Render(m.Errors[i])))
+		}
+	}
+
+	button := func(label string, focused bool) string {
+		if focused {
+			return m.Parent.CursorStyle.Render("[" + label + "]")
+		}
+		return m.Parent.ItemStyle.Render(" " + label + " ")
+	}
+
+	b.WriteString("\n  ")
+	b.WriteString(button("Submit", m.Focus == m.submitIndex()))
+	b.WriteString("  ")
+	b.WriteString(button("Cancel", m.Focus == m.cancelIndex()))
+	keys := formKeys()
+	b.WriteString("\n\n")
+	b.WriteString(HelpView([]key.Binding{keys.next, keys.previous, keys.press, keys.submit, keys.cancel}, false))
+	b.WriteString("\n")
+
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package tea
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robertgouveia/do-my-job/tea/teatest"
+)
+
+func TestFormValidatesAndSubmits(t *testing.T) {
+	root := Create("Main")
+
+	var got []string
+	root.AddForm("Edit", func() []FormField {
+		return []FormField{
+			{Label: "Name", Validate: Required},
+			{Label: "Password", Value: "secret", Masked: true},
+		}
+	}, func(values []string) Result {
+		got = values
+		return Back()
+	})
+
+	d := teatest.New(t, root).Press("enter")
+	d.RequireGolden("form_open")
+
+	d.Press("ctrl+s")
+	if got != nil {
+		t.Fatalf("form submitted with a blank required field")
+	}
+	d.RequireGolden("form_invalid")
+
+	d.Type("bob").Press("ctrl+s")
+	if len(got) != 2 || got[0] != "bob" || got[1] != "secret" {
+		t.Fatalf("submitted %v", got)
+	}
+	if d.Model != root {
+		t.Errorf("expected to return to the menu, got %T", d.Model)
+	}
+}
+
+func TestFormCancel(t *testing.T) {
+	root := Create("Main")
+	called := false
+	root.AddForm("Edit", func() []FormField {
+		return []FormField{{Label: "Name"}}
+	}, func([]string) Result {
+		called = true
+		return Back()
+	})
+
+	d := teatest.New(t, root).Press("enter").Type("x").Press("esc")
+	if called || d.Model != root {
+		t.Errorf("esc should leave the form without submitting")
+	}
+}
+
+func TestInputScreensFollowTheKeymap(t *testing.T) {
+	defer SetKeyMap(DefaultKeyMap())
+
+	k, err := DefaultKeyMap().Apply(KeyConfig{Bindings: map[string][]string{
+		"back":   {"ctrl+x"},
+		"select": {"h"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyMap(k)
+
+	root := Create("Main")
+	var got []string
+	root.AddForm("Edit", func() []FormField {
+		return []FormField{{Label: "Name"}}
+	}, func(values []string) Result {
+		got = values
+		return Back()
+	})
+	root.AddGuardedMenuItem("Drop", func() string { return "PROD" }, func() Result {
+		return Show("dropped")
+	})
+
+	// esc is no longer back, and h types rather than selecting, as select
+	// falls back to enter on input screens
+	d := teatest.New(t, root).Press("h").Press("esc").Type("hal")
+	if _, ok := d.Model.(*FormModel); !ok {
+		t.Fatalf("expected to stay on the form, got %T", d.Model)
+	}
+	if !strings.Contains(d.View(), "ctrl+x cancel") {
+		t.Errorf("footer does not show the remapped key:\n%s", d.View())
+	}
+
+	d.Press("ctrl+x")
+	if got != nil || d.Model != root {
+		t.Fatalf("ctrl+x should cancel the form, got %T with %v", d.Model, got)
+	}
+
+	d.Press("h").Type("hal").Press("enter", "enter")
+	if len(got) != 1 || got[0] != "hal" {
+		t.Fatalf("submitted %v, want [hal]", got)
+	}
+
+	d.Press("down", "h").Type("PROD").Press("ctrl+x")
+	if d.Model != root {
+		t.Errorf("ctrl+x should cancel the confirmation, got %T", d.Model)
+	}
+}
@@ -0,0 +1,214 @@
+package tea
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+)
+
+const (
+	QuitExits  = "quit"
+	QuitGoBack = "back"
+)
+
+type KeyMap struct {
+	Up        key.Binding
+	Down      key.Binding
+	Select    key.Binding
+	Back      key.Binding
+	Quit      key.Binding
+	ForceQuit key.Binding
+	Search    key.Binding
+	Help      key.Binding
+	Retry     key.Binding
+	Refresh   key.Binding
+	Submit    key.Binding
+
+	// QuitBehaviour decides whether the quit key exits the program or only
+	// goes back when pressed inside a submenu.
+	QuitBehaviour string
+	// ConfirmQuit asks before the quit key exits.
+	ConfirmQuit bool
+}
+
+// KeyConfig is the user-editable form of a KeyMap. Bindings maps an action
+// name (see KeyMap.actions) to the keys that trigger it.
+type KeyConfig struct {
+	Bindings      map[string][]string `json:"bindings,omitempty"`
+	QuitBehaviour string              `json:"quit_behaviour,omitempty"`
+	// ConfirmQuit is a pointer so that leaving it out keeps the default.
+	ConfirmQuit *bool `json:"confirm_quit,omitempty"`
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Up:        key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+		Down:      key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+		Select:    key.NewBinding(key.WithKeys("enter", " "), key.WithHelp("enter", "select")),
+		Back:      key.NewBinding(key.WithKeys("esc", "backspace", "left", "h"), key.WithHelp("esc", "back")),
+		Quit:      key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
+		ForceQuit: key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "force quit")),
+		Search:    key.NewBinding(key.WithKeys("ctrl+p", "/"), key.WithHelp("/", "search")),
+		Help:      key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "help")),
+		Retry:     key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "retry")),
+		Refresh:   key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh")),
+		Submit:    key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "submit")),
+
+		QuitBehaviour: QuitExits,
+		ConfirmQuit:   true,
+	}
+}
+
+func (k *KeyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"up":         &k.Up,
+		"down":       &k.Down,
+		"select":     &k.Select,
+		"back":       &k.Back,
+		"quit":       &k.Quit,
+		"force_quit": &k.ForceQuit,
+		"search":     &k.Search,
+		"help":       &k.Help,
+		"retry":      &k.Retry,
+		"refresh":    &k.Refresh,
+		"submit":     &k.Submit,
+	}
+}
+
+// textKeys are used by text inputs to type and move through the text.
+var textKeys = map[string]bool{
+	"backspace": true, "delete": true, "left": true, "right": true, "home": true, "end": true,
+}
+
+func isTextKey(k string) bool {
+	return textKeys[k] || len([]rune(k)) == 1
+}
+
+// forInput narrows each binding to the keys a text input does not use, so
+// screens with one follow the keymap without Back eating backspace or
+// Select eating spaces. A binding left with no keys falls back to the
+// default's.
+func (k KeyMap) forInput() KeyMap {
+	defaultKeys := DefaultKeyMap()
+	defaults := defaultKeys.actions()
+
+	for action, binding := range k.actions() {
+		kept := nonTextKeys(binding.Keys())
+		if len(kept) == 0 {
+			fallback := defaults[action]
+			kept = nonTextKeys(fallback.Keys())
+			binding.SetHelp(strings.Join(kept, "/"), fallback.Help().Desc)
+		}
+		binding.SetKeys(kept...)
+	}
+	return k
+}
+
+// inputBinding adds extra keys to b and labels it with every key it has,
+// as the input keymap may have dropped some of the ones in its help.
+func inputBinding(b key.Binding, desc string, extra ...string) key.Binding {
+	keys := append(append([]string(nil), extra...), b.Keys()...)
+	b.SetKeys(keys...)
+	b.SetHelp(strings.Join(keys, "/"), desc)
+	return b
+}
+
+func nonTextKeys(keys []string) []string {
+	var kept []string
+	for _, k := range keys {
+		if !isTextKey(k) {
+			kept = append(kept, k)
+		}
+	}
+	return kept
+}
+
+// Apply returns a copy of k with the overrides in config. Unknown action
+// names are reported rather than ignored so typos are noticed.
+func (k KeyMap) Apply(config KeyConfig) (KeyMap, error) {
+	actions := k.actions()
+
+	var unknown []string
+	for action, keys := range config.Bindings {
+		binding, ok := actions[action]
+		if !ok {
+			unknown = append(unknown, action)
+			continue
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		binding.SetKeys(keys...)
+		binding.SetHelp(strings.Join(keys, "/"), binding.Help().Desc)
+	}
+
+	switch config.QuitBehaviour {
+	case "":
+	case QuitExits, QuitGoBack:
+		k.QuitBehaviour = config.QuitBehaviour
+	default:
+		unknown = append(unknown, "quit_behaviour="+config.QuitBehaviour)
+	}
+	if config.ConfirmQuit != nil {
+		k.ConfirmQuit = *config.ConfirmQuit
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return k, fmt.Errorf("unknown key settings: %s", strings.Join(unknown, ", "))
+	}
+	return k, nil
+}
+
+var (
+	keys      = DefaultKeyMap()
+	inputKeys = keys.forInput()
+)
+
+func SetKeyMap(k KeyMap) {
+	keys = k
+	inputKeys = k.forInput()
+}
+
+func Keys() KeyMap {
+	return keys
+}
+
+// bindingHelp adapts a list of bindings to bubbles' help.KeyMap.
+type bindingHelp []key.Binding
+
+func (b bindingHelp) ShortHelp() []key.Binding {
+	return b
+}
+
+func (b bindingHelp) FullHelp() [][]key.Binding {
+	var columns [][]key.Binding
+	for i := 0; i < len(b); i += 4 {
+		columns = append(columns, b[i:min(i+4, len(b))])
+	}
+	return columns
+}
+
+// HelpView renders the enabled bindings as a one-line footer, or as the full
+// overlay when full is set.
+func HelpView(bindings []key.Binding, full bool) string {
+	h := help.New()
+	if currentTheme.Plain {
+		h.Styles = help.Styles{}
+	}
+
+	var enabled bindingHelp
+	for _, b := range bindings {
+		if b.Enabled() {
+			enabled = append(enabled, b)
+		}
+	}
+
+	if full {
+		return h.FullHelpView(enabled.FullHelp())
+	}
+	return h.ShortHelpView(enabled.ShortHelp())
+}
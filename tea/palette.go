@@ -0,0 +1,269 @@
+package tea
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	bubble "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	pathSeparator  = " › "
+	paletteResults = 10
+)
+
+type PaletteEntry struct {
+	Path  []string
+	Menu  *TeaModel
+	Index int
+}
+
+func (e PaletteEntry) String() string {
+	return strings.Join(e.Path, pathSeparator)
+}
+
+// collectEntries lists every item reachable from root along with the menu
+// that owns it. The root title is left out of the paths.
+func collectEntries(root *TeaModel) []PaletteEntry {
+	var entries []PaletteEntry
+	visited := map[*TeaModel]bool{}
+
+	var walk func(menu *TeaModel, path []string)
+	walk = func(menu *TeaModel, path []string) {
+		if visited[menu] {
+			return
+		}
+		visited[menu] = true
+
+		for i, item := range menu.MenuItems {
+			itemPath := append(append([]string(nil), path...), item.Title)
+			entries = append(entries, PaletteEntry{Path: itemPath, Menu: menu, Index: i})
+
+			if item.ItemType == SubmenuItem && item.SubMenu != nil {
+				walk(item.SubMenu, itemPath)
+			}
+		}
+	}
+	walk(root, nil)
+
+	return entries
+}
+
+// fuzzyScore matches query as a case-insensitive subsequence of target.
+// Consecutive characters and word starts score higher. ok is false when
+// query is not a subsequence at all.
+func fuzzyScore(query, target string) (score int, ok bool) {
+	q := []rune(strings.ToLower(query))
+	t := []rune(strings.ToLower(target))
+
+	qi, streak := 0, 0
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if unicode.IsSpace(q[qi]) {
+			qi++
+			ti--
+			continue
+		}
+
+		if t[ti] != q[qi] {
+			streak = 0
+			continue
+		}
+
+		score++
+		streak++
+		score += streak * 2
+		if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+			score += 5
+		}
+		qi++
+	}
+
+	for qi < len(q) && unicode.IsSpace(q[qi]) {
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+
+	return score*100 - len(t), true
+}
+
+type paletteKeyMap struct {
+	up, down, open, cancel key.Binding
+}
+
+// paletteKeys adds ctrl+k and ctrl+j for moving through the results, as
+// the search box takes the keymap's letter keys.
+func paletteKeys() paletteKeyMap {
+	return paletteKeyMap{
+		up:     inputBinding(inputKeys.Up, "up", "ctrl+k"),
+		down:   inputBinding(inputKeys.Down, "down", "ctrl+j"),
+		open:   inputBinding(inputKeys.Select, "go"),
+		cancel: inputBinding(inputKeys.Back, "cancel"),
+	}
+}
+
+type PaletteModel struct {
+	Origin  *TeaModel
+	Input   textinput.Model
+	Entries []PaletteEntry
+	Matches []PaletteEntry
+	Cursor  int
+}
+
+func NewPaletteModel(origin *TeaModel) *PaletteModel {
+	ti := textinput.New()
+	ti.Prompt = "/ "
+	ti.Placeholder = "Search menus..."
+	ti.Focus()
+	ti.CharLimit = 100
+	ti.Width = 50
+
+	m := &PaletteModel{
+		Origin:  origin,
+		Input:   ti,
+		Entries: collectEntries(origin.Root()),
+	}
+	m.filter()
+
+	return m
+}
+
+func (m *PaletteModel) filter() {
+	query := strings.TrimSpace(m.Input.Value())
+
+	type scored struct {
+		entry PaletteEntry
+		score int
+	}
+
+	var results []scored
+	for _, entry := range m.Entries {
+		if query == "" {
+			results = append(results, scored{entry: entry})
+			continue
+		}
+		if score, ok := fuzzyScore(query, entry.String()); ok {
+			results = append(results, scored{entry: entry, score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+
+	m.Matches = m.Matches[:0]
+	for _, r := range results {
+		m.Matches = append(m.Matches, r.entry)
+	}
+	m.Cursor = 0
+}
+
+// jump moves every menu on the way to the entry so its cursor points down
+// the chain, which keeps Esc/back walking the same route a user would take.
+// A disabled menu on the way stops the jump at the item that opens it.
+// Landing in another menu opens it the way navigating there would.
+func (m *PaletteModel) jump(entry PaletteEntry) (bubble.Model, bubble.Cmd) {
+	entry.Menu.Cursor = entry.Index
+	target := entry.Menu
+
+	for menu := entry.Menu; menu.Parent != nil; menu = menu.Parent {
+		for i, item := range menu.Parent.MenuItems {
+			if item.SubMenu == menu {
+				menu.Parent.Cursor = i
+				break
+			}
+		}
+		if menu.disabledReason() != "" {
+			target = menu.Parent
+		}
+	}
+
+	if target == m.Origin {
+		return target, nil
+	}
+
+	return target.enter()
+}
+
+func (m PaletteModel) Init() bubble.Cmd {
+	return textinput.Blink
+}
+
+func (m *PaletteModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
+	var cmd bubble.Cmd
+
+	switch msg := msg.(type) {
+	case bubble.KeyMsg:
+		keys := paletteKeys()
+		switch {
+		case key.Matches(msg, keys.cancel):
+			return m.Origin, nil
+		case key.Matches(msg, inputKeys.ForceQuit):
+			return m, bubble.Quit
+		case key.Matches(msg, keys.up):
+			if m.Cursor > 0 {
+				m.Cursor--
+			}
+			return m, nil
+		case key.Matches(msg, keys.down):
+			if m.Cursor < len(m.Matches)-1 {
+				m.Cursor++
+			}
+			return m, nil
+		case key.Matches(msg, keys.open):
+			if len(m.Matches) > 0 {
+				return m.jump(m.Matches[m.Cursor])
+			}
+			return m, nil
+		}
+	}
+
+	before := m.Input.Value()
+	m.Input, cmd = m.Input.Update(msg)
+	if m.Input.Value() != before {
+		m.filter()
+	}
+
+	return m, cmd
+}
+
+func (m PaletteModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.Origin.Root().BannerView())
+	b.WriteString(fmt.Sprintf("%s\n\n", m.Origin.TitleStyle.Render("Go to...")))
+	b.WriteString(m.Input.View())
+	b.WriteString("\n\n")
+
+	start := 0
+	if m.Cursor >= paletteResults {
+		start = m.Cursor - paletteResults + 1
+	}
+	end := min(start+paletteResults, len(m.Matches))
+
+	for i := start; i < end; i++ {
+		cursor := " "
+		if i == m.Cursor {
+			cursor = m.Origin.CursorStyle.Render(currentTheme.CursorGlyph)
+		}
+		b.WriteString(fmt.Sprintf("%s %s\n", cursor, m.Origin.ItemStyle.Render(m.Matches[i].String())))
+	}
+
+	if len(m.Matches) == 0 {
+		b.WriteString("  No matches\n")
+	} else if len(m.Matches) > paletteResults {
+		b.WriteString(fmt.Sprintf("\n  %d of %d results\n", end-start, len(m.Matches)))
+	}
+
+	keys := paletteKeys()
+	b.WriteString("\n")
+	b.WriteString(HelpView([]key.Binding{keys.up, keys.down, keys.open, keys.cancel}, false))
+	b.WriteString("\n")
+
+	return b.String()
+}
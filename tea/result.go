@@ -0,0 +1,147 @@
+package tea
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	bubble "github.com/charmbracelet/bubbletea"
+)
+
+type Navigation int
+
+const (
+	ShowOutput Navigation = iota
+	GoBack
+	Stay
+	ShowError
+)
+
+// Result is what a menu action hands back to tell the menu where to go next.
+type Result struct {
+	Nav    Navigation
+	Output string
+	Err    error
+	Retry  func() Result
+
+	retryTask Task
+	// confirm is the guard of the item that failed, asked again on retry.
+	confirm func() string
+}
+
+func Show(output string) Result {
+	return Result{Nav: ShowOutput, Output: output}
+}
+
+func Back() Result {
+	return Result{Nav: GoBack}
+}
+
+// Fail shows err on an error screen. When retry is set the user can run it
+// again without leaving the menu, so anything they typed is kept.
+func Fail(err error, retry func() Result) Result {
+	return Result{Nav: ShowError, Err: err, Retry: retry}
+}
+
+func None() Result {
+	return Result{Nav: Stay}
+}
+
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+	resultChrome  = 8
+)
+
+type ResultModel struct {
+	Parent   *TeaModel
+	Title    string
+	Viewport viewport.Model
+	ShowHelp bool
+}
+
+func NewResultModel(parent *TeaModel, title, output string) *ResultModel {
+	width, height := parent.Root().size()
+	box := currentTheme.BoxStyle()
+
+	vp := viewport.New(width-box.GetHorizontalFrameSize(), max(height-resultChrome-box.GetVerticalFrameSize(), 5))
+	vp.SetContent(strings.Trim(output, "\n"))
+
+	return &ResultModel{
+		Parent:   parent,
+		Title:    title,
+		Viewport: vp,
+	}
+}
+
+func (m ResultModel) Init() bubble.Cmd {
+	return nil
+}
+
+func (m *ResultModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
+	var cmd bubble.Cmd
+
+	switch msg := msg.(type) {
+	case bubble.WindowSizeMsg:
+		m.Parent.Root().setSize(msg)
+		box := currentTheme.BoxStyle()
+		m.Viewport.Width = msg.Width - box.GetHorizontalFrameSize()
+		m.Viewport.Height = max(msg.Height-resultChrome-box.GetVerticalFrameSize(), 5)
+	case bubble.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.ForceQuit):
+			return m, bubble.Quit
+		case key.Matches(msg, m.back()):
+			return m.Parent, nil
+		case key.Matches(msg, keys.Quit):
+			return m.Parent.Quit(m.Parent)
+		case key.Matches(msg, keys.Help):
+			m.ShowHelp = !m.ShowHelp
+			return m, nil
+		}
+	}
+
+	m.Viewport, cmd = m.Viewport.Update(msg)
+	return m, cmd
+}
+
+// back is the Back binding without the keys the viewport scrolls with, so
+// paging through a long result with space or arrows does not leave it.
+func (m ResultModel) back() key.Binding {
+	vk := m.Viewport.KeyMap
+	scrolling := make(map[string]bool)
+	for _, b := range []key.Binding{vk.PageDown, vk.PageUp, vk.HalfPageDown, vk.HalfPageUp, vk.Up, vk.Down, vk.Left, vk.Right} {
+		for _, k := range b.Keys() {
+			scrolling[k] = true
+		}
+	}
+
+	var kept []string
+	for _, k := range keys.Back.Keys() {
+		if !scrolling[k] {
+			kept = append(kept, k)
+		}
+	}
+	if len(kept) == 0 {
+		kept = []string{"esc"}
+	}
+	return key.NewBinding(key.WithKeys(kept...), key.WithHelp(kept[0], keys.Back.Help().Desc))
+}
+
+func (m ResultModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.Parent.Root().BannerView())
+	b.WriteString(fmt.Sprintf("%s\n\n", m.Parent.TitleStyle.Render(m.Title)))
+	b.WriteString(currentTheme.BoxStyle().Render(m.Viewport.View()))
+	b.WriteString(fmt.Sprintf("\n%3.f%%\n\n", m.Viewport.ScrollPercent()*100))
+
+	scrollUp := key.NewBinding(key.WithKeys(m.Viewport.KeyMap.Up.Keys()...), key.WithHelp("↑/k", "scroll up"))
+	scrollDown := key.NewBinding(key.WithKeys(m.Viewport.KeyMap.Down.Keys()...), key.WithHelp("↓/j", "scroll down"))
+	pageDown := key.NewBinding(key.WithKeys(m.Viewport.KeyMap.PageDown.Keys()...), key.WithHelp("space", "page down"))
+	b.WriteString(HelpView([]key.Binding{scrollUp, scrollDown, pageDown, m.back(), QuitBinding(true), keys.ForceQuit, keys.Help}, m.ShowHelp))
+	b.WriteString("\n")
+
+	return b.String()
+}
@@ -1,10 +1,11 @@
 package tea
 
 import (
+	"errors"
 	"fmt"
 	"strings"
-	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	bubble "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -16,16 +17,23 @@ const (
 	ContentItem MenuItemType = iota
 	SubmenuItem
 	TextInputItem
+	ModelItem
+	FormItem
 )
 
 type MenuItem struct {
 	Title     string
-	Content   func() string
+	Content   func() Result
+	Task      Task
 	SubMenu   *TeaModel
 	ItemType  MenuItemType
 	OnSubmit  func(string)
 	Prompt    string
 	InputDesc string
+	Confirm   func() string
+	Model     func(parent *TeaModel) bubble.Model
+	Fields    func() []FormField
+	OnForm    func(values []string) Result
 }
 
 type TextInputModel struct {
@@ -63,14 +71,16 @@ func (m *TextInputModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
 
 	switch msg := msg.(type) {
 	case bubble.KeyMsg:
-		switch msg.Type {
-		case bubble.KeyEnter:
+		switch {
+		case key.Matches(msg, inputKeys.Select):
 			if m.OnSubmit != nil {
 				m.OnSubmit(m.TextInput.Value())
 			}
 			return m.Parent, nil
-		case bubble.KeyEsc:
+		case key.Matches(msg, inputKeys.Back):
 			return m.Parent, nil
+		case key.Matches(msg, inputKeys.ForceQuit):
+			return m, bubble.Quit
 		}
 	}
 
@@ -81,6 +91,7 @@ func (m *TextInputModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
 func (m TextInputModel) View() string {
 	var b strings.Builder
 
+	b.WriteString(m.Parent.Root().BannerView())
 	b.WriteString(fmt.Sprintf("%s\n\n", m.Title))
 
 	if m.Description != "" {
@@ -89,7 +100,8 @@ func (m TextInputModel) View() string {
 
 	b.WriteString(fmt.Sprintf("%s\n\n", m.Prompt))
 	b.WriteString(m.TextInput.View())
-	b.WriteString("\n\nPress Enter to submit, Esc to cancel")
+	b.WriteString("\n\n")
+	b.WriteString(HelpView([]key.Binding{inputBinding(inputKeys.Select, "submit"), inputBinding(inputKeys.Back, "cancel")}, false))
 
 	return b.String()
 }
@@ -99,16 +111,33 @@ type TeaModel struct {
 	Title     string
 	Parent    *TeaModel
 
-	SelectedMenu string
+	// Banner, Width and Height are only kept on the root menu.
+	Banner func() string
+	Width  int
+	Height int
+
+	// Status adds segments to the status bar while this menu or any of its
+	// children is open. Warning, when it returns something, is shown next
+	// to the item that opens this menu.
+	Status  func() []StatusSegment
+	Warning func() string
+	// Disabled, when it returns a reason, stops this menu being opened and
+	// shows the reason instead. OnOpen runs each time the menu is entered
+	// from its parent, e.g. to refresh what Disabled reports.
+	Disabled func() string
+	OnOpen   func() bubble.Cmd
 
 	Cursor   int
 	Selected int
 	Quitting bool
-	Back     bool
+
+	ShowHelp       bool
+	ConfirmingQuit bool
 
 	TitleStyle  lipgloss.Style
 	ItemStyle   lipgloss.Style
 	CursorStyle lipgloss.Style
+	ErrorStyle  lipgloss.Style
 }
 
 func (m TeaModel) Init() bubble.Cmd {
@@ -119,17 +148,48 @@ func Create(title string) *TeaModel {
 	return &TeaModel{
 		MenuItems:   []MenuItem{},
 		Title:       title,
-		TitleStyle:  lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FAFAFA")),
-		ItemStyle:   lipgloss.NewStyle().Foreground(lipgloss.Color("#DDDDDD")),
-		CursorStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("#FF875F")),
+		TitleStyle:  currentTheme.TitleStyle(),
+		ItemStyle:   currentTheme.ItemStyle(),
+		CursorStyle: currentTheme.CursorStyle(),
+		ErrorStyle:  currentTheme.ErrorStyle(),
 	}
 }
 
-func (m *TeaModel) AddMenuItem(title string, contentFunc func() string) {
+func (m *TeaModel) AddMenuItem(title string, contentFunc func() Result) {
+	m.MenuItems = append(m.MenuItems, MenuItem{
+		Title:    title,
+		Content:  contentFunc,
+		ItemType: ContentItem,
+	})
+}
+
+// AddGuardedMenuItem behaves like AddMenuItem, but when confirm returns a
+// non-empty phrase the user has to type it before contentFunc runs.
+func (m *TeaModel) AddGuardedMenuItem(title string, confirm func() string, contentFunc func() Result) {
 	m.MenuItems = append(m.MenuItems, MenuItem{
 		Title:    title,
 		Content:  contentFunc,
 		ItemType: ContentItem,
+		Confirm:  confirm,
+	})
+}
+
+// AddTask runs task in the background behind a spinner so long database
+// calls and downloads do not freeze the menu.
+func (m *TeaModel) AddTask(title string, task Task) {
+	m.MenuItems = append(m.MenuItems, MenuItem{
+		Title:    title,
+		Task:     task,
+		ItemType: ContentItem,
+	})
+}
+
+func (m *TeaModel) AddGuardedTask(title string, confirm func() string, task Task) {
+	m.MenuItems = append(m.MenuItems, MenuItem{
+		Title:    title,
+		Task:     task,
+		ItemType: ContentItem,
+		Confirm:  confirm,
 	})
 }
 
@@ -142,6 +202,27 @@ func (m *TeaModel) AddSubmenu(title string, submenu *TeaModel) {
 	})
 }
 
+// AddModel opens a custom screen built by newModel. The screen is expected
+// to return parent from its Update when the user backs out.
+func (m *TeaModel) AddModel(title string, newModel func(parent *TeaModel) bubble.Model) {
+	m.MenuItems = append(m.MenuItems, MenuItem{
+		Title:    title,
+		ItemType: ModelItem,
+		Model:    newModel,
+	})
+}
+
+// AddForm shows several fields on one screen. fields is called each time
+// the form opens so it can prefill current values.
+func (m *TeaModel) AddForm(title string, fields func() []FormField, onSubmit func(values []string) Result) {
+	m.MenuItems = append(m.MenuItems, MenuItem{
+		Title:    title,
+		ItemType: FormItem,
+		Fields:   fields,
+		OnForm:   onSubmit,
+	})
+}
+
 func (m *TeaModel) AddTextInput(title, prompt, description string, onSubmit func(string)) {
 	m.MenuItems = append(m.MenuItems, MenuItem{
 		Title:     title,
@@ -153,57 +234,43 @@ func (m *TeaModel) AddTextInput(title, prompt, description string, onSubmit func
 }
 
 func (m *TeaModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
-	if m.Back {
-		m.Back = false
-		return m.Parent, nil
-	}
-
 	switch msg := msg.(type) {
+	case bubble.WindowSizeMsg:
+		m.Root().setSize(msg)
 	case bubble.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
+		if m.ConfirmingQuit {
+			m.ConfirmingQuit = false
+			if msg.String() == "y" || msg.String() == "Y" {
+				m.Quitting = true
+				return m, bubble.Quit
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, keys.ForceQuit):
 			m.Quitting = true
-			return m, quitAfterDelay()
-		case "up", "k":
+			return m, bubble.Quit
+		case key.Matches(msg, keys.Quit):
+			return m.Quit(m.Parent)
+		case key.Matches(msg, keys.Help):
+			m.ShowHelp = !m.ShowHelp
+		case key.Matches(msg, keys.Search):
+			palette := NewPaletteModel(m)
+			return palette, textinput.Blink
+		case key.Matches(msg, keys.Up):
 			if m.Cursor > 0 {
 				m.Cursor--
 			}
-		case "down", "j":
+		case key.Matches(msg, keys.Down):
 			if m.Cursor < len(m.MenuItems)-1 {
 				m.Cursor++
 			}
-		case "enter", " ":
-			m.Selected = m.Cursor
-			selectedItem := m.MenuItems[m.Selected]
-
-			switch selectedItem.ItemType {
-			case SubmenuItem:
-				return selectedItem.SubMenu, nil
-			case ContentItem:
-				if selectedItem.Content != nil {
-					result := selectedItem.Content()
-
-					if result == "back" && m.Parent != nil {
-						return m.Parent, nil
-					}
-
-					m.SelectedMenu = result
-					m.Quitting = true
-					return m, quitAfterDelay()
-				}
-			case TextInputItem:
-				inputModel := NewTextInputModel(
-					m,
-					selectedItem.Title,
-					selectedItem.Prompt,
-					selectedItem.InputDesc,
-					selectedItem.OnSubmit,
-				)
-				m.Parent.Cursor = 0
-				m.Parent.Selected = 0
-				return inputModel, textinput.Blink
+		case key.Matches(msg, keys.Select):
+			if len(m.MenuItems) > 0 {
+				return m.selectItem()
 			}
-		case "backspace", "esc", "left", "h":
+		case key.Matches(msg, keys.Back):
 			if m.Parent != nil {
 				return m.Parent, nil
 			}
@@ -213,53 +280,221 @@ func (m *TeaModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
 	return m, nil
 }
 
+func (m *TeaModel) selectItem() (bubble.Model, bubble.Cmd) {
+	m.Selected = m.Cursor
+	selectedItem := m.MenuItems[m.Selected]
+
+	switch selectedItem.ItemType {
+	case SubmenuItem:
+		submenu := selectedItem.SubMenu
+		if reason := submenu.disabledReason(); reason != "" {
+			return m.finish(selectedItem.Title, Fail(errors.New(reason), nil))
+		}
+		return submenu.enter()
+	case ContentItem:
+		return m.start(selectedItem)
+	case TextInputItem:
+		inputModel := NewTextInputModel(
+			m,
+			selectedItem.Title,
+			selectedItem.Prompt,
+			selectedItem.InputDesc,
+			selectedItem.OnSubmit,
+		)
+		if m.Parent != nil {
+			m.Parent.Cursor = 0
+			m.Parent.Selected = 0
+		}
+		return inputModel, textinput.Blink
+	case ModelItem:
+		model := selectedItem.Model(m)
+		return model, model.Init()
+	case FormItem:
+		formModel := NewFormModel(m, selectedItem.Title, selectedItem.Fields(), selectedItem.OnForm)
+		return formModel, textinput.Blink
+	}
+
+	return m, nil
+}
+
+// enter opens the menu from its parent. Every way into a menu goes through
+// here so OnOpen always runs.
+func (m *TeaModel) enter() (bubble.Model, bubble.Cmd) {
+	if m.OnOpen != nil {
+		return m, m.OnOpen()
+	}
+	return m, nil
+}
+
+func (m *TeaModel) disabledReason() string {
+	if m.Disabled == nil {
+		return ""
+	}
+	return m.Disabled()
+}
+
+// start runs item, asking for its confirmation phrase first when it has
+// one. Retries come through here too, so they are confirmed again.
+func (m *TeaModel) start(item MenuItem) (bubble.Model, bubble.Cmd) {
+	if item.Confirm != nil {
+		if phrase := item.Confirm(); phrase != "" {
+			confirmModel := NewConfirmModel(m, phrase, item)
+			return confirmModel, textinput.Blink
+		}
+	}
+
+	return m.run(item)
+}
+
+func (m *TeaModel) run(item MenuItem) (bubble.Model, bubble.Cmd) {
+	switch {
+	case item.Task != nil:
+		taskModel := NewTaskModel(m, item.Title, item.Task)
+		taskModel.Confirm = item.Confirm
+		return taskModel, taskModel.Init()
+	case item.Content != nil:
+		result := item.Content()
+		result.confirm = item.Confirm
+		return m.finish(item.Title, result)
+	}
+
+	return m, nil
+}
+
+func (m *TeaModel) finish(title string, result Result) (bubble.Model, bubble.Cmd) {
+	switch result.Nav {
+	case GoBack:
+		if m.Parent != nil {
+			return m.Parent, nil
+		}
+	case ShowOutput:
+		return NewResultModel(m, title, result.Output), nil
+	case ShowError:
+		errorModel := NewErrorModel(m, title, result.Err, result.Retry)
+		errorModel.RetryTask = result.retryTask
+		errorModel.Confirm = result.confirm
+		return errorModel, nil
+	}
+
+	return m, nil
+}
+
+func (m *TeaModel) size() (int, int) {
+	width, height := m.Width, m.Height
+	if width == 0 {
+		width = defaultWidth
+	}
+	if height == 0 {
+		height = defaultHeight
+	}
+	return width, height
+}
+
+func (m *TeaModel) setSize(msg bubble.WindowSizeMsg) {
+	m.Width, m.Height = msg.Width, msg.Height
+}
+
+func (m *TeaModel) Root() *TeaModel {
+	root := m
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return root
+}
+
+func (m *TeaModel) BannerView() string {
+	if m.Banner == nil {
+		return ""
+	}
+
+	banner := m.Banner()
+	if banner == "" {
+		return ""
+	}
+	return banner + "\n\n"
+}
+
 func (m TeaModel) View() string {
 	if m.Quitting {
 		return "Exiting...\n"
 	}
 
-	s := fmt.Sprintf("%s\n\n", m.TitleStyle.Render(m.Title))
+	s := m.Root().BannerView()
+	s += m.StatusBarView()
+	s += fmt.Sprintf("%s\n\n", m.TitleStyle.Render(m.Title))
 
 	for i, item := range m.MenuItems {
 		cursor := " "
 		if m.Cursor == i {
-			cursor = m.CursorStyle.Render(">")
+			cursor = m.CursorStyle.Render(currentTheme.CursorGlyph)
 		}
 
 		indicator := ""
 		switch item.ItemType {
 		case SubmenuItem:
-			indicator = " ▶"
-		case TextInputItem:
-			indicator = " ✎"
+			indicator = " " + currentTheme.SubmenuGlyph
+		case TextInputItem, FormItem:
+			indicator = " " + currentTheme.InputGlyph
+		case ModelItem:
+			indicator = " " + currentTheme.ScreenGlyph
+		}
+
+		if item.ItemType == SubmenuItem {
+			if reason := item.SubMenu.disabledReason(); reason != "" {
+				indicator += " " + m.ErrorStyle.Render("(disabled) "+reason)
+			} else if item.SubMenu.Warning != nil {
+				if warning := item.SubMenu.Warning(); warning != "" {
+					indicator += " " + m.ErrorStyle.Render(currentTheme.WarnGlyph+" "+warning)
+				}
+			}
 		}
 
 		s += fmt.Sprintf("%s [%s]%s\n", cursor, m.ItemStyle.Render(item.Title), indicator)
 	}
 
-	s += "\n(↑/↓) Navigate   (Enter) Select   "
-	if m.Parent != nil {
-		s += "(Esc) Back   "
+	s += "\n"
+	if m.ConfirmingQuit {
+		s += m.ErrorStyle.Render("Quit? (y/N)") + "\n"
+		return s
 	}
-	s += "(q) Quit\n"
+	s += HelpView(m.helpBindings(), m.ShowHelp) + "\n"
 
 	return s
 }
 
-func (m *TeaModel) Run() (bubble.Model, error) {
-	p := bubble.NewProgram(m)
-	model, err := p.Run()
+func (m TeaModel) helpBindings() []key.Binding {
+	back := keys.Back
+	back.SetEnabled(m.Parent != nil)
 
-	if teaModel, ok := model.(*TeaModel); ok && teaModel.SelectedMenu != "" {
-		fmt.Println(teaModel.SelectedMenu)
-	}
+	return []key.Binding{keys.Up, keys.Down, keys.Select, back, keys.Search, QuitBinding(m.Parent != nil), keys.ForceQuit, keys.Help}
+}
 
-	return model, err
+// Quit handles the quit key for m and the screens opened from it, so it
+// means the same everywhere: with QuitGoBack it returns to back when
+// there is somewhere to go, otherwise it exits from m, asking first when
+// ConfirmQuit is set.
+func (m *TeaModel) Quit(back *TeaModel) (bubble.Model, bubble.Cmd) {
+	if keys.QuitBehaviour == QuitGoBack && back != nil {
+		return back, nil
+	}
+	if keys.ConfirmQuit {
+		m.ConfirmingQuit = true
+		return m, nil
+	}
+	m.Quitting = true
+	return m, bubble.Quit
 }
 
-func quitAfterDelay() bubble.Cmd {
-	return func() bubble.Msg {
-		time.Sleep(500 * time.Millisecond)
-		return bubble.Quit()
+// QuitBinding is the quit key labelled with what Quit will do.
+func QuitBinding(canGoBack bool) key.Binding {
+	quit := keys.Quit
+	if keys.QuitBehaviour == QuitGoBack && canGoBack {
+		quit.SetHelp(quit.Help().Key, "back")
 	}
+	return quit
+}
+
+func (m *TeaModel) Run() (bubble.Model, error) {
+	p := bubble.NewProgram(m)
+	return p.Run()
 }
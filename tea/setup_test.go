@@ -0,0 +1,300 @@
+package tea
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	bubble "github.com/charmbracelet/bubbletea"
+	"github.com/robertgouveia/do-my-job/tea/teatest"
+)
+
+func TestMain(m *testing.M) {
+	SetTheme(PlainTheme)
+	os.Exit(m.Run())
+}
+
+func testMenu() *TeaModel {
+	root := Create("Main")
+	root.AddMenuItem("Hello", func() Result { return Show("hello world") })
+
+	sub := Create("Settings")
+	sub.AddMenuItem("Reset", func() Result { return Back() })
+	sub.AddMenuItem("Nothing", func() Result { return None() })
+	root.AddSubmenu("Settings", sub)
+
+	return root
+}
+
+func TestMenuNavigation(t *testing.T) {
+	root := testMenu()
+	d := teatest.New(t, root)
+	d.RequireGolden("menu_root")
+
+	d.Press("down", "enter")
+	if d.Model != root.MenuItems[1].SubMenu {
+		t.Fatalf("expected the Settings submenu, got %T", d.Model)
+	}
+	d.RequireGolden("menu_submenu")
+
+	d.Press("down", "esc")
+	if d.Model != root {
+		t.Fatalf("expected esc to return to the root menu")
+	}
+	if root.Cursor != 1 {
+		t.Errorf("root cursor = %d, want it kept on Settings", root.Cursor)
+	}
+}
+
+func TestMenuCursorStaysInBounds(t *testing.T) {
+	root := testMenu()
+	d := teatest.New(t, root)
+
+	d.Press("up", "up", "down", "down", "down")
+	if root.Cursor != 1 {
+		t.Errorf("cursor = %d, want 1", root.Cursor)
+	}
+
+	empty := Create("Empty")
+	teatest.New(t, empty).Press("enter", "down")
+}
+
+func TestDisabledSubmenu(t *testing.T) {
+	root := testMenu()
+	sub := root.MenuItems[1].SubMenu
+
+	opened := 0
+	sub.OnOpen = func() bubble.Cmd {
+		opened++
+		return nil
+	}
+
+	reason := "no access"
+	sub.Disabled = func() string { return reason }
+
+	d := teatest.New(t, root)
+	if !strings.Contains(d.View(), "[Settings] >> (disabled) no access") {
+		t.Errorf("disabled reason not shown:\n%s", d.View())
+	}
+
+	d.Press("down", "enter")
+	if _, ok := d.Model.(*ErrorModel); !ok || opened != 0 {
+		t.Fatalf("expected the reason instead of the menu, got %T", d.Model)
+	}
+
+	reason = ""
+	d.Press("esc", "enter")
+	if d.Model != sub || opened != 1 {
+		t.Errorf("expected the menu to open once enabled, got %T (opened %d)", d.Model, opened)
+	}
+}
+
+func TestPaletteJumpOpensMenu(t *testing.T) {
+	root := testMenu()
+	sub := root.MenuItems[1].SubMenu
+
+	opened := 0
+	sub.OnOpen = func() bubble.Cmd {
+		opened++
+		return nil
+	}
+
+	d := teatest.New(t, root).Press("/").Type("nothing").Press("enter")
+	if d.Model != sub || opened != 1 {
+		t.Fatalf("expected the jump to open Settings once, got %T (opened %d)", d.Model, opened)
+	}
+	if sub.Cursor != 1 {
+		t.Errorf("cursor = %d, want the jumped-to item", sub.Cursor)
+	}
+
+	d.Press("/").Type("reset").Press("enter")
+	if opened != 1 {
+		t.Errorf("jumping within the open menu should not open it again (opened %d)", opened)
+	}
+}
+
+func TestContentItemShowsResult(t *testing.T) {
+	root := testMenu()
+	d := teatest.New(t, root).Press("enter")
+
+	if _, ok := d.Model.(*ResultModel); !ok {
+		t.Fatalf("expected a result screen, got %T", d.Model)
+	}
+	d.RequireGolden("result")
+
+	d.Press("esc")
+	if d.Model != root {
+		t.Fatalf("expected esc to return to the menu")
+	}
+}
+
+func TestResultScrollsWithSpaceAndEnter(t *testing.T) {
+	root := Create("Main")
+	root.AddMenuItem("Long", func() Result {
+		return Show(strings.Repeat("line\n", 200))
+	})
+
+	d := teatest.New(t, root).Press("enter")
+	result, ok := d.Model.(*ResultModel)
+	if !ok {
+		t.Fatalf("expected a result screen, got %T", d.Model)
+	}
+
+	d.Press("space", "enter")
+	if d.Model != result {
+		t.Fatalf("space and enter should stay on the result, got %T", d.Model)
+	}
+	if result.Viewport.YOffset == 0 {
+		t.Error("space should page down")
+	}
+
+	d.Press("backspace")
+	if d.Model != root {
+		t.Errorf("expected backspace to return to the menu, got %T", d.Model)
+	}
+}
+
+func TestGoBackResultReturnsToParent(t *testing.T) {
+	root := testMenu()
+	d := teatest.New(t, root).Press("down", "enter", "enter")
+
+	if d.Model != root {
+		t.Fatalf("expected Back() to return to the root menu, got %T", d.Model)
+	}
+}
+
+func TestTextInputResetsParentCursor(t *testing.T) {
+	root := testMenu()
+	sub := root.MenuItems[1].SubMenu
+
+	var submitted string
+	sub.AddTextInput("Rename", "New name:", "", func(value string) { submitted = value })
+
+	d := teatest.New(t, root).Press("down", "enter", "down", "down", "enter")
+	if _, ok := d.Model.(*TextInputModel); !ok {
+		t.Fatalf("expected a text input, got %T", d.Model)
+	}
+	if root.Cursor != 0 || root.Selected != 0 {
+		t.Errorf("opening a text input should reset the parent menu's cursor, got %d", root.Cursor)
+	}
+
+	d.Type("abc").Press("enter")
+	if submitted != "abc" {
+		t.Errorf("submitted %q, want %q", submitted, "abc")
+	}
+	if d.Model != sub {
+		t.Errorf("expected to return to the submenu, got %T", d.Model)
+	}
+
+	// back on the parent, the cursor is on its first item
+	d.Press("esc")
+	d.RequireGolden("menu_after_text_input")
+}
+
+func TestTextInputOnRootMenu(t *testing.T) {
+	root := Create("Main")
+	root.AddTextInput("Name", "Name:", "", nil)
+
+	d := teatest.New(t, root).Press("enter")
+	if _, ok := d.Model.(*TextInputModel); !ok {
+		t.Fatalf("expected a text input, got %T", d.Model)
+	}
+	d.Press("esc")
+	if d.Model != root {
+		t.Fatalf("expected esc to return to the menu")
+	}
+}
+
+func TestHelpOverlay(t *testing.T) {
+	d := teatest.New(t, testMenu()).Press("?")
+	d.RequireGolden("menu_help")
+}
+
+func TestQuitBehaviour(t *testing.T) {
+	defer SetKeyMap(DefaultKeyMap())
+
+	k, err := DefaultKeyMap().Apply(KeyConfig{QuitBehaviour: QuitGoBack})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyMap(k)
+
+	root := testMenu()
+	d := teatest.New(t, root).Press("down", "enter", "q")
+	if d.Model != root {
+		t.Fatalf("expected q to go back from a submenu")
+	}
+
+	d.Press("q")
+	if !root.ConfirmingQuit {
+		t.Fatalf("expected a quit confirmation on the root menu")
+	}
+	d.RequireGolden("menu_quit_confirm")
+
+	d.Press("n")
+	if root.ConfirmingQuit || root.Quitting {
+		t.Errorf("answering no should cancel the quit")
+	}
+
+	d.Press("q", "y")
+	if !root.Quitting {
+		t.Errorf("answering yes should quit")
+	}
+}
+
+func TestQuitIsConfirmedByDefault(t *testing.T) {
+	root := testMenu()
+	d := teatest.New(t, root).Press("down", "enter", "q")
+	sub := root.MenuItems[1].SubMenu
+	if d.Model != sub || !sub.ConfirmingQuit || sub.Quitting {
+		t.Fatalf("expected q to ask before quitting, got %T", d.Model)
+	}
+
+	// the result screen quits the same way, asking on the menu behind it
+	root = testMenu()
+	d = teatest.New(t, root).Press("enter", "q")
+	if d.Model != root || !root.ConfirmingQuit {
+		t.Fatalf("expected q on a result to ask before quitting, got %T", d.Model)
+	}
+
+	off := false
+	k, err := DefaultKeyMap().Apply(KeyConfig{ConfirmQuit: &off})
+	if err != nil || k.ConfirmQuit {
+		t.Errorf("confirm_quit false should turn the question off, got %v, %v", k.ConfirmQuit, err)
+	}
+	if k, _ := DefaultKeyMap().Apply(KeyConfig{}); !k.ConfirmQuit {
+		t.Error("leaving confirm_quit out should keep the default")
+	}
+}
+
+func TestQuitGoesBackFromResults(t *testing.T) {
+	defer SetKeyMap(DefaultKeyMap())
+
+	k, err := DefaultKeyMap().Apply(KeyConfig{QuitBehaviour: QuitGoBack})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyMap(k)
+
+	root := testMenu()
+	d := teatest.New(t, root).Press("enter", "q")
+	if d.Model != root || root.ConfirmingQuit {
+		t.Errorf("expected q to go back from the result, got %T", d.Model)
+	}
+}
+
+func TestApplyReportsUnknownActions(t *testing.T) {
+	k, err := DefaultKeyMap().Apply(KeyConfig{
+		Bindings:      map[string][]string{"quit": {"x"}, "jump": {"z"}},
+		QuitBehaviour: "explode",
+	})
+	if err == nil {
+		t.Fatal("expected an error for unknown settings")
+	}
+	if got := k.Quit.Keys(); len(got) != 1 || got[0] != "x" {
+		t.Errorf("known bindings should still apply, got %v", got)
+	}
+	if k.QuitBehaviour != QuitExits {
+		t.Errorf("invalid quit behaviour should keep the default, got %q", k.QuitBehaviour)
+	}
+}
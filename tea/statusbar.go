@@ -0,0 +1,80 @@
+package tea
+
+import (
+	"strings"
+)
+
+// StatusSegment is one "Label: Value" field of the status bar. Warn marks
+// values the user should act on before running anything.
+type StatusSegment struct {
+	Label string
+	Value string
+	Warn  bool
+}
+
+// Breadcrumb is the path of menu titles from the root to m.
+func (m *TeaModel) Breadcrumb() string {
+	var titles []string
+	for menu := m; menu != nil; menu = menu.Parent {
+		titles = append([]string{menu.Title}, titles...)
+	}
+
+	separator := " › "
+	if currentTheme.Plain {
+		separator = " > "
+	}
+	return strings.Join(titles, separator)
+}
+
+// statusSegments gathers Status from the root down to m. A menu closer to
+// m replaces a segment with the same label set further up.
+func (m *TeaModel) statusSegments() []StatusSegment {
+	var chain []*TeaModel
+	for menu := m; menu != nil; menu = menu.Parent {
+		chain = append([]*TeaModel{menu}, chain...)
+	}
+
+	var segments []StatusSegment
+	index := make(map[string]int)
+	for _, menu := range chain {
+		if menu.Status == nil {
+			continue
+		}
+		for _, segment := range menu.Status() {
+			if i, ok := index[segment.Label]; ok {
+				segments[i] = segment
+				continue
+			}
+			index[segment.Label] = len(segments)
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments
+}
+
+func (m *TeaModel) StatusBarView() string {
+	segments := m.statusSegments()
+	if m.Parent == nil && len(segments) == 0 {
+		// the title already says where we are
+		return ""
+	}
+
+	style := currentTheme.StatusStyle()
+	separator := style.Render(" │ ")
+	if currentTheme.Plain {
+		separator = " | "
+	}
+
+	parts := []string{style.Render(m.Breadcrumb())}
+	for _, segment := range segments {
+		text := segment.Label + ": " + segment.Value
+		if segment.Warn {
+			parts = append(parts, m.ErrorStyle.Render(currentTheme.WarnGlyph+" "+text))
+			continue
+		}
+		parts = append(parts, style.Render(text))
+	}
+
+	return strings.Join(parts, separator) + "\n\n"
+}
@@ -0,0 +1,190 @@
+package tea
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	bubble "github.com/charmbracelet/bubbletea"
+)
+
+// Progress is reported by a running Task. A negative Fraction means the
+// task cannot tell how far along it is, so only Message is shown.
+type Progress struct {
+	Fraction float64
+	Message  string
+}
+
+// A Task runs off the UI goroutine. It should stop when ctx is cancelled
+// and may call report as often as it likes.
+type Task func(ctx context.Context, report func(Progress)) Result
+
+var taskID int64
+
+type taskDoneMsg struct {
+	id     int64
+	result Result
+}
+
+type taskProgressMsg struct {
+	id       int64
+	progress Progress
+}
+
+type TaskModel struct {
+	Parent     *TeaModel
+	Title      string
+	Task       Task
+	Spinner    spinner.Model
+	Bar        progress.Model
+	Started    time.Time
+	Progress   Progress
+	Cancelling bool
+	// Confirm is the guard the task was started behind, asked again
+	// before a failed task is retried.
+	Confirm func() string
+
+	id      int64
+	ctx     context.Context
+	cancel  context.CancelFunc
+	updates chan Progress
+}
+
+func NewTaskModel(parent *TeaModel, title string, task Task) *TaskModel {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = parent.CursorStyle
+
+	return &TaskModel{
+		Parent:   parent,
+		Title:    title,
+		Task:     task,
+		Spinner:  s,
+		Bar:      progress.New(progress.WithDefaultGradient(), progress.WithWidth(40)),
+		Progress: Progress{Fraction: -1},
+		id:       atomic.AddInt64(&taskID, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+		updates:  make(chan Progress, 16),
+	}
+}
+
+func (m *TaskModel) Init() bubble.Cmd {
+	m.Started = time.Now()
+	return bubble.Batch(m.Spinner.Tick, m.run(), m.waitForProgress())
+}
+
+func (m *TaskModel) run() bubble.Cmd {
+	id, ctx, task, updates := m.id, m.ctx, m.Task, m.updates
+
+	return func() bubble.Msg {
+		report := func(p Progress) {
+			select {
+			case updates <- p:
+			default:
+				// the UI is behind; drop the update rather than block the task
+			}
+		}
+		return taskDoneMsg{id: id, result: task(ctx, report)}
+	}
+}
+
+func (m *TaskModel) waitForProgress() bubble.Cmd {
+	id, ctx, updates := m.id, m.ctx, m.updates
+
+	return func() bubble.Msg {
+		select {
+		case p := <-updates:
+			return taskProgressMsg{id: id, progress: p}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *TaskModel) Update(msg bubble.Msg) (bubble.Model, bubble.Cmd) {
+	switch msg := msg.(type) {
+	case taskDoneMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+		m.cancel()
+
+		result := msg.result
+		if result.Nav == ShowError && result.Retry == nil {
+			result.retryTask = m.Task
+		}
+		result.confirm = m.Confirm
+		return m.Parent.finish(m.Title, result)
+	case taskProgressMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+		m.Progress = msg.progress
+		return m, m.waitForProgress()
+	case spinner.TickMsg:
+		var cmd bubble.Cmd
+		m.Spinner, cmd = m.Spinner.Update(msg)
+		return m, cmd
+	case bubble.WindowSizeMsg:
+		m.Parent.Root().setSize(msg)
+	case bubble.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Back):
+			// The first Esc asks the task to stop and waits for it to report
+			// back; a second one leaves it to finish in the background.
+			if m.Cancelling {
+				return m.Parent, nil
+			}
+			m.Cancelling = true
+			m.cancel()
+		case key.Matches(msg, keys.ForceQuit):
+			m.cancel()
+			return m, bubble.Quit
+		}
+	}
+
+	return m, nil
+}
+
+func (m *TaskModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.Parent.Root().BannerView())
+	b.WriteString(fmt.Sprintf("%s\n\n", m.Parent.TitleStyle.Render(m.Title)))
+
+	status := "Running"
+	if m.Cancelling {
+		status = "Cancelling"
+	}
+	elapsed := time.Since(m.Started).Round(100 * time.Millisecond)
+	b.WriteString(fmt.Sprintf("%s %s... %s\n", m.Spinner.View(), status, elapsed))
+
+	if m.Progress.Fraction >= 0 {
+		b.WriteString("\n")
+		b.WriteString(m.Bar.ViewAs(min(m.Progress.Fraction, 1)))
+		b.WriteString("\n")
+	}
+	if m.Progress.Message != "" {
+		b.WriteString(fmt.Sprintf("\n%s\n", m.Progress.Message))
+	}
+
+	cancel := keys.Back
+	if m.Cancelling {
+		cancel.SetHelp(cancel.Help().Key, "leave running in background")
+	} else {
+		cancel.SetHelp(cancel.Help().Key, "cancel")
+	}
+	b.WriteString("\n")
+	b.WriteString(HelpView([]key.Binding{cancel, keys.ForceQuit}, false))
+	b.WriteString("\n")
+
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package tea
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/robertgouveia/do-my-job/tea/teatest"
+)
+
+func TestTaskShowsResult(t *testing.T) {
+	root := Create("Main")
+	root.AddTask("Work", func(ctx context.Context, report func(Progress)) Result {
+		report(Progress{Fraction: 0.5, Message: "halfway"})
+		return Show("done")
+	})
+
+	d := teatest.New(t, root).Press("enter")
+	if _, ok := d.Model.(*TaskModel); !ok {
+		t.Fatalf("expected the task screen, got %T", d.Model)
+	}
+
+	d.WaitFor("done")
+	if _, ok := d.Model.(*ResultModel); !ok {
+		t.Fatalf("expected the result screen, got %T", d.Model)
+	}
+}
+
+func TestTaskRetryAfterFailure(t *testing.T) {
+	root := Create("Main")
+
+	attempts := 0
+	root.AddTask("Flaky", func(ctx context.Context, report func(Progress)) Result {
+		attempts++
+		if attempts == 1 {
+			return Fail(errors.New("connection reset"), nil)
+		}
+		return Show("recovered")
+	})
+
+	d := teatest.New(t, root).Press("enter").WaitFor("connection reset")
+	d.RequireGolden("task_error")
+
+	d.Press("r").WaitFor("recovered")
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestGuardedTaskRetryIsConfirmedAgain(t *testing.T) {
+	root := Create("Main")
+
+	attempts := 0
+	root.AddGuardedTask("Drop", func() string { return "PROD" }, func(ctx context.Context, report func(Progress)) Result {
+		attempts++
+		return Fail(errors.New("deadlock victim"), nil)
+	})
+
+	d := teatest.New(t, root).Press("enter").Type("PROD").Press("enter").WaitFor("deadlock victim")
+
+	d.Press("r")
+	if _, ok := d.Model.(*ConfirmModel); !ok {
+		t.Fatalf("expected the retry to ask for confirmation, got %T", d.Model)
+	}
+	if attempts != 1 {
+		t.Fatalf("the retry ran before it was confirmed, attempts = %d", attempts)
+	}
+
+	d.Type("PROD").Press("enter").WaitFor("deadlock victim")
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestTaskCancel(t *testing.T) {
+	root := Create("Main")
+	root.AddTask("Slow", func(ctx context.Context, report func(Progress)) Result {
+		<-ctx.Done()
+		return Show("cancelled")
+	})
+
+	d := teatest.New(t, root).Press("enter", "esc").WaitFor("cancelled")
+	if _, ok := d.Model.(*ResultModel); !ok {
+		t.Fatalf("expected the result screen, got %T", d.Model)
+	}
+}
+
+func TestConfirmGuardsItem(t *testing.T) {
+	root := Create("Main")
+	ran := false
+	root.AddGuardedMenuItem("Drop", func() string { return "PROD" }, func() Result {
+		ran = true
+		return None()
+	})
+
+	d := teatest.New(t, root).Press("enter").Type("prod").Press("enter")
+	if ran {
+		t.Fatal("item ran with the wrong phrase")
+	}
+
+	d.Press("esc")
+	d = teatest.New(t, root).Press("enter").Type("PROD").Press("enter")
+	if !ran {
+		t.Fatal("item did not run after confirming")
+	}
+}
@@ -0,0 +1,170 @@
+// Package teatest drives bubbletea models without a terminal so menus can
+// be tested with scripted key presses and golden View() snapshots.
+package teatest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bubble "github.com/charmbracelet/bubbletea"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// DefaultTimeout bounds WaitFor when no timeout is given.
+const DefaultTimeout = 2 * time.Second
+
+// Driver holds the model currently on screen. Key presses replace it with
+// whatever Update returns, the same way bubble.Program would.
+type Driver struct {
+	t     testing.TB
+	Model bubble.Model
+	msgs  chan bubble.Msg
+}
+
+// New starts m and runs its Init command in the background.
+func New(t testing.TB, m bubble.Model) *Driver {
+	t.Helper()
+
+	d := &Driver{t: t, Model: m, msgs: make(chan bubble.Msg, 64)}
+	d.Send(bubble.WindowSizeMsg{Width: 100, Height: 40})
+	d.run(m.Init())
+	return d
+}
+
+// Send delivers msg to the current model and schedules the command it
+// returns. Commands run on their own goroutine; their messages are only
+// delivered by WaitFor so tests stay deterministic.
+func (d *Driver) Send(msg bubble.Msg) *Driver {
+	d.t.Helper()
+
+	model, cmd := d.Model.Update(msg)
+	d.Model = model
+	d.run(cmd)
+	return d
+}
+
+func (d *Driver) run(cmd bubble.Cmd) {
+	if cmd == nil {
+		return
+	}
+
+	go func() {
+		msg := cmd()
+		if batch, ok := msg.(bubble.BatchMsg); ok {
+			for _, c := range batch {
+				d.run(c)
+			}
+			return
+		}
+		if msg != nil {
+			d.msgs <- msg
+		}
+	}()
+}
+
+// Press sends each key by name, e.g. "enter", "down", "esc", "ctrl+s".
+// Anything that is not a named key is sent as typed runes.
+func (d *Driver) Press(keys ...string) *Driver {
+	d.t.Helper()
+
+	for _, k := range keys {
+		d.Send(keyMsg(k))
+	}
+	return d
+}
+
+// Type sends s one rune at a time, as if typed.
+func (d *Driver) Type(s string) *Driver {
+	d.t.Helper()
+
+	for _, r := range s {
+		d.Send(bubble.KeyMsg{Type: bubble.KeyRunes, Runes: []rune{r}})
+	}
+	return d
+}
+
+var namedKeys = map[string]bubble.KeyType{
+	"enter":     bubble.KeyEnter,
+	"esc":       bubble.KeyEsc,
+	"up":        bubble.KeyUp,
+	"down":      bubble.KeyDown,
+	"left":      bubble.KeyLeft,
+	"right":     bubble.KeyRight,
+	"tab":       bubble.KeyTab,
+	"shift+tab": bubble.KeyShiftTab,
+	"backspace": bubble.KeyBackspace,
+	"space":     bubble.KeySpace,
+	"ctrl+c":    bubble.KeyCtrlC,
+	"ctrl+p":    bubble.KeyCtrlP,
+	"ctrl+s":    bubble.KeyCtrlS,
+	"ctrl+x":    bubble.KeyCtrlX,
+}
+
+func keyMsg(k string) bubble.KeyMsg {
+	if t, ok := namedKeys[k]; ok {
+		return bubble.KeyMsg{Type: t}
+	}
+	return bubble.KeyMsg{Type: bubble.KeyRunes, Runes: []rune(k)}
+}
+
+// View is the current model's output with trailing spaces trimmed from
+// each line, so padding changes in lipgloss do not break snapshots.
+func (d *Driver) View() string {
+	lines := strings.Split(d.Model.View(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WaitFor delivers messages from running commands until the view contains
+// want, failing the test after DefaultTimeout.
+func (d *Driver) WaitFor(want string) *Driver {
+	d.t.Helper()
+
+	deadline := time.After(DefaultTimeout)
+	for !strings.Contains(d.View(), want) {
+		select {
+		case msg := <-d.msgs:
+			d.Send(msg)
+		case <-deadline:
+			d.t.Fatalf("timed out waiting for %q in view:\n%s", want, d.View())
+		}
+	}
+	return d
+}
+
+// RequireGolden compares the current view with testdata/<name>.golden.
+// Run the tests with -update to write the files after an intended change.
+func (d *Driver) RequireGolden(name string) {
+	d.t.Helper()
+	RequireGolden(d.t, name, d.View())
+}
+
+func RequireGolden(t testing.TB, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create testdata: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if string(want) != got {
+		t.Errorf("view does not match %s\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+}
@@ -0,0 +1,210 @@
+package tea
+
+import (
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Theme holds every colour and glyph the menus draw with. Colours are hex
+// strings; an empty string leaves the terminal default.
+type Theme struct {
+	Name         string `json:"name"`
+	Base         string `json:"base,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Item         string `json:"item,omitempty"`
+	Cursor       string `json:"cursor,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Border       string `json:"border,omitempty"`
+	BorderColour string `json:"border_colour,omitempty"`
+	CursorGlyph  string `json:"cursor_glyph,omitempty"`
+	SubmenuGlyph string `json:"submenu_glyph,omitempty"`
+	InputGlyph   string `json:"input_glyph,omitempty"`
+	ScreenGlyph  string `json:"screen_glyph,omitempty"`
+	WarnGlyph    string `json:"warn_glyph,omitempty"`
+	Plain        bool   `json:"plain,omitempty"`
+}
+
+var (
+	DarkTheme = Theme{
+		Name:         "dark",
+		Title:        "#FAFAFA",
+		Item:         "#DDDDDD",
+		Cursor:       "#FF875F",
+		Error:        "#FF5F5F",
+		Border:       "rounded",
+		BorderColour: "#585858",
+		CursorGlyph:  ">",
+		SubmenuGlyph: "▶",
+		InputGlyph:   "✎",
+		ScreenGlyph:  "◆",
+		WarnGlyph:    "⚠",
+	}
+
+	LightTheme = Theme{
+		Name:         "light",
+		Title:        "#1C1C1C",
+		Item:         "#3A3A3A",
+		Cursor:       "#AF3A00",
+		Error:        "#AF0000",
+		Border:       "rounded",
+		BorderColour: "#9E9E9E",
+		CursorGlyph:  ">",
+		SubmenuGlyph: "▶",
+		InputGlyph:   "✎",
+		ScreenGlyph:  "◆",
+		WarnGlyph:    "⚠",
+	}
+
+	HighContrastTheme = Theme{
+		Name:         "high-contrast",
+		Title:        "#FFFFFF",
+		Item:         "#FFFFFF",
+		Cursor:       "#FFFF00",
+		Error:        "#FF0000",
+		Border:       "thick",
+		BorderColour: "#FFFFFF",
+		CursorGlyph:  "=>",
+		SubmenuGlyph: ">>",
+		InputGlyph:   "[edit]",
+		ScreenGlyph:  "[view]",
+		WarnGlyph:    "(!)",
+	}
+
+	// PlainTheme drops all ANSI styling for screen readers and logs.
+	PlainTheme = Theme{
+		Name:         "plain",
+		Border:       "none",
+		CursorGlyph:  ">",
+		SubmenuGlyph: ">>",
+		InputGlyph:   "[edit]",
+		ScreenGlyph:  "[view]",
+		WarnGlyph:    "(!)",
+		Plain:        true,
+	}
+)
+
+var currentTheme = DarkTheme
+
+func SetTheme(t Theme) {
+	currentTheme = t
+	if t.Plain {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+func CurrentTheme() Theme {
+	return currentTheme
+}
+
+func Preset(name string) (Theme, bool) {
+	switch name {
+	case "dark":
+		return DarkTheme, true
+	case "light":
+		return LightTheme, true
+	case "high-contrast":
+		return HighContrastTheme, true
+	case "plain":
+		return PlainTheme, true
+	case "", "auto":
+		return AutoTheme(), true
+	}
+	return Theme{}, false
+}
+
+// AutoTheme honours NO_COLOR and otherwise picks light or dark from the
+// terminal background.
+func AutoTheme() Theme {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return PlainTheme
+	}
+	if lipgloss.HasDarkBackground() {
+		return DarkTheme
+	}
+	return LightTheme
+}
+
+// Merge fills the empty fields of override from t so a user theme only has
+// to list what it changes.
+func (t Theme) Merge(override Theme) Theme {
+	pick := func(o, base string) string {
+		if o != "" {
+			return o
+		}
+		return base
+	}
+
+	return Theme{
+		Name:         pick(override.Name, t.Name),
+		Title:        pick(override.Title, t.Title),
+		Item:         pick(override.Item, t.Item),
+		Cursor:       pick(override.Cursor, t.Cursor),
+		Error:        pick(override.Error, t.Error),
+		Border:       pick(override.Border, t.Border),
+		BorderColour: pick(override.BorderColour, t.BorderColour),
+		CursorGlyph:  pick(override.CursorGlyph, t.CursorGlyph),
+		SubmenuGlyph: pick(override.SubmenuGlyph, t.SubmenuGlyph),
+		InputGlyph:   pick(override.InputGlyph, t.InputGlyph),
+		ScreenGlyph:  pick(override.ScreenGlyph, t.ScreenGlyph),
+		WarnGlyph:    pick(override.WarnGlyph, t.WarnGlyph),
+		Plain:        t.Plain || override.Plain,
+	}
+}
+
+func (t Theme) style(colour string, bold bool) lipgloss.Style {
+	style := lipgloss.NewStyle()
+	if t.Plain {
+		return style
+	}
+	if colour != "" {
+		style = style.Foreground(lipgloss.Color(colour))
+	}
+	return style.Bold(bold)
+}
+
+func (t Theme) TitleStyle() lipgloss.Style {
+	return t.style(t.Title, true)
+}
+
+func (t Theme) ItemStyle() lipgloss.Style {
+	return t.style(t.Item, false)
+}
+
+func (t Theme) CursorStyle() lipgloss.Style {
+	return t.style(t.Cursor, false)
+}
+
+func (t Theme) ErrorStyle() lipgloss.Style {
+	return t.style(t.Error, true)
+}
+
+func (t Theme) StatusStyle() lipgloss.Style {
+	if t.Plain {
+		return lipgloss.NewStyle()
+	}
+	return t.style(t.Item, false).Faint(true)
+}
+
+func (t Theme) BoxStyle() lipgloss.Style {
+	style := lipgloss.NewStyle()
+
+	switch t.Border {
+	case "normal":
+		style = style.Border(lipgloss.NormalBorder())
+	case "rounded":
+		style = style.Border(lipgloss.RoundedBorder())
+	case "thick":
+		style = style.Border(lipgloss.ThickBorder())
+	case "double":
+		style = style.Border(lipgloss.DoubleBorder())
+	default:
+		return style
+	}
+
+	if !t.Plain && t.BorderColour != "" {
+		style = style.BorderForeground(lipgloss.Color(t.BorderColour))
+	}
+	return style
+}